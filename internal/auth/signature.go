@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrSignatureExpired = errors.New("signature timestamp is outside the tolerance window")
+	ErrInvalidAPIKey    = errors.New("invalid api key")
+)
+
+// ValidateSignature checks a "v1=<hex>" HMAC-SHA256 signature over
+// "<timestamp>.<body>", where timestamp is the header exactly as sent, in
+// Unix seconds, and may be at most tolerance away from now. The signature
+// header may list several signatures separated by commas, and any of
+// secrets may have made them, so either side can rotate its secret
+// without downtime.
+func ValidateSignature(signatureHeader, timestampHeader string, body []byte, secrets [][]byte, tolerance time.Duration, now time.Time) error {
+	timestamp, err := strconv.ParseInt(strings.TrimSpace(timestampHeader), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrSignatureExpired
+	}
+
+	signed := append([]byte(timestampHeader+"."), body...)
+	for _, signature := range strings.Split(signatureHeader, ",") {
+		hexSum, ok := strings.CutPrefix(strings.TrimSpace(signature), "v1=")
+		if !ok {
+			continue
+		}
+		sum, err := hex.DecodeString(hexSum)
+		if err != nil {
+			continue
+		}
+
+		for _, secret := range secrets {
+			if len(secret) == 0 {
+				continue
+			}
+			mac := hmac.New(sha256.New, secret)
+			mac.Write(signed)
+			if hmac.Equal(sum, mac.Sum(nil)) {
+				return nil
+			}
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// CheckAPIKey compares the request's API key with expected in constant
+// time. An empty expected key matches nothing.
+func CheckAPIKey(headers http.Header, expected []byte) error {
+	apiKey, err := GetAPIKey(headers)
+	if err != nil {
+		return err
+	}
+	if len(expected) == 0 || subtle.ConstantTimeCompare([]byte(apiKey), expected) != 1 {
+		return ErrInvalidAPIKey
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func sign(secret string, timestamp int64, body string) string {
+	return signHeader(secret, strconv.FormatInt(timestamp, 10), body)
+}
+
+func signHeader(secret, timestamp, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + body))
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidateSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := `{"event":"user.upgraded"}`
+	secrets := [][]byte{[]byte("current"), []byte("previous")}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	cases := []struct {
+		name      string
+		signature string
+		timestamp string
+		body      string
+		expected  error
+	}{
+		{"current secret", sign("current", now.Unix(), body), timestamp, body, nil},
+		{"previous secret", sign("previous", now.Unix(), body), timestamp, body, nil},
+		{"one of several signatures", "v1=00ff, " + sign("current", now.Unix(), body), timestamp, body, nil},
+		{"unknown secret", sign("other", now.Unix(), body), timestamp, body, ErrInvalidSignature},
+		{"tampered body", sign("current", now.Unix(), body), timestamp, `{"event":"user.downgraded"}`, ErrInvalidSignature},
+		{"timestamp not signed", sign("current", now.Unix()-1, body), timestamp, body, ErrInvalidSignature},
+		{"missing version", sign("current", now.Unix(), body)[3:], timestamp, body, ErrInvalidSignature},
+		{"invalid timestamp", sign("current", now.Unix(), body), "yesterday", body, ErrInvalidSignature},
+		{"too old", sign("current", now.Unix()-301, body), strconv.FormatInt(now.Unix()-301, 10), body, ErrSignatureExpired},
+		{"too far ahead", sign("current", now.Unix()+301, body), strconv.FormatInt(now.Unix()+301, 10), body, ErrSignatureExpired},
+		{"leading zero", signHeader("current", "0"+timestamp, body), "0" + timestamp, body, nil},
+		{"plus sign", signHeader("current", "+"+timestamp, body), "+" + timestamp, body, nil},
+		{"surrounding whitespace", signHeader("current", " "+timestamp+" ", body), " " + timestamp + " ", body, nil},
+		{"normalized timestamp signed", sign("current", now.Unix(), body), "0" + timestamp, body, ErrInvalidSignature},
+		{"within tolerance", sign("current", now.Unix()-300, body), strconv.FormatInt(now.Unix()-300, 10), body, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ValidateSignature(c.signature, c.timestamp, []byte(c.body), secrets, 5*time.Minute, now)
+			if !errors.Is(err, c.expected) {
+				t.Errorf("expected %v, got %v", c.expected, err)
+			}
+		})
+	}
+}
+
+func TestValidateSignatureIgnoresEmptySecrets(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	signature := sign("", now.Unix(), "{}")
+	err := ValidateSignature(signature, strconv.FormatInt(now.Unix(), 10), []byte("{}"), [][]byte{nil}, time.Minute, now)
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("an unset secret must not validate anything, got %v", err)
+	}
+}
+
+func TestCheckAPIKey(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("Authorization", "ApiKey f271c81ff7084ee5b99a5091b42d486e")
+
+	if err := CheckAPIKey(headers, []byte("f271c81ff7084ee5b99a5091b42d486e")); err != nil {
+		t.Errorf("expected the key to match, got %v", err)
+	}
+	if err := CheckAPIKey(headers, []byte("another")); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("expected a mismatch, got %v", err)
+	}
+	if err := CheckAPIKey(headers, nil); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("an unset key must match nothing, got %v", err)
+	}
+	if err := CheckAPIKey(http.Header{}, []byte("key")); err == nil {
+		t.Error("expected a missing header to fail")
+	}
+}
@@ -0,0 +1,71 @@
+package chirptext
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGraphemes(t *testing.T) {
+	cases := map[string]int{
+		"":                   0,
+		"hello":              5,
+		"привет":             6,
+		"e\u0301":            1, // e + combining acute accent
+		"👍":                  1,
+		"👍🏽":                 1, // skin tone modifier
+		"👨‍👩‍👧":              1, // ZWJ family sequence
+		"🇯🇵🇫🇷":               2, // two flags
+		"🇯🇵🇫":                2, // a flag and a lone regional indicator
+		"❤️":                 1, // emoji presentation selector
+		"\r\n":               1,
+		"한국어":                3,
+		"\u1100\u1161\u11a8": 1, // decomposed Hangul syllable
+		"a👍b":                3,
+	}
+
+	for input, expected := range cases {
+		if actual := Graphemes(input); actual != expected {
+			t.Errorf("Graphemes(%q) = %d, expected %d", input, actual, expected)
+		}
+	}
+}
+
+func TestLength(t *testing.T) {
+	cases := []struct {
+		body     string
+		expected int
+	}{
+		{"hello", 5},
+		{"see https://example.com/a/very/long/path?with=query", 4 + 23},
+		{"two http://a.example and HTTPS://b.example links", 4 + 23 + 5 + 23 + 6},
+		{"no url here: example.com", 24},
+		{strings.Repeat("🙂", 140), 140},
+	}
+
+	for _, c := range cases {
+		if actual := Length(c.body, 23); actual != c.expected {
+			t.Errorf("Length(%q) = %d, expected %d", c.body, actual, c.expected)
+		}
+	}
+}
+
+func TestHashtags(t *testing.T) {
+	cases := []struct {
+		body     string
+		expected []string
+	}{
+		{"no tags", []string{}},
+		{"#Go is #fun, #go!", []string{"go", "fun"}},
+		{"#привет_мир and #日本", []string{"привет_мир", "日本"}},
+		{"issue#12 and #1 are not tags, #2fast is", []string{"2fast"}},
+		{"see https://example.com/#anchor #real", []string{"real"}},
+		{"&#39; ##double", []string{}},
+	}
+
+	for _, c := range cases {
+		actual := Hashtags(c.body)
+		if strings.Join(actual, ",") != strings.Join(c.expected, ",") || len(actual) != len(c.expected) {
+			t.Errorf("Hashtags(%q) = %q, expected %q", c.body, actual, c.expected)
+		}
+	}
+}
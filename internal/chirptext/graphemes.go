@@ -0,0 +1,158 @@
+package chirptext
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Graphemes counts the user-perceived characters in s, following the
+// extended grapheme cluster rules of Unicode UAX #29 closely enough for
+// length limits: combining marks, emoji modifiers, ZWJ emoji sequences,
+// flags, CRLF and Hangul syllables each count once. "é" spelled as "e"
+// plus a combining accent, "👍🏽" and "👨‍👩‍👧" are all one character.
+func Graphemes(s string) int {
+	count := 0
+	prevClass := classOther
+	// riCount is the run length of regional indicators, two make a flag
+	riCount := 0
+	// afterZWJ is set while inside a pictographic sequence ending in ZWJ
+	inPictographic, afterZWJ := false, false
+
+	for i, w := 0, 0; i < len(s); i += w {
+		var r rune
+		r, w = utf8.DecodeRuneInString(s[i:])
+		class := classify(r)
+
+		if i == 0 || breaksBetween(prevClass, class, riCount, afterZWJ) {
+			count++
+		}
+
+		if class == classRegionalIndicator {
+			riCount++
+		} else {
+			riCount = 0
+		}
+
+		switch {
+		case class == classPictographic:
+			inPictographic, afterZWJ = true, false
+		case class == classZWJ:
+			afterZWJ = inPictographic
+		case class == classExtend:
+			afterZWJ = false
+		default:
+			inPictographic, afterZWJ = false, false
+		}
+
+		prevClass = class
+	}
+
+	return count
+}
+
+type graphemeClass int
+
+const (
+	classOther graphemeClass = iota
+	classCR
+	classLF
+	classControl
+	classExtend
+	classZWJ
+	classRegionalIndicator
+	classPictographic
+	classHangulL
+	classHangulV
+	classHangulT
+	classHangulLV
+	classHangulLVT
+)
+
+func breaksBetween(prevClass, class graphemeClass, riCount int, afterZWJ bool) bool {
+	switch {
+	// GB3: CR LF
+	case prevClass == classCR && class == classLF:
+		return false
+	// GB4, GB5: around controls
+	case prevClass == classCR || prevClass == classLF || prevClass == classControl:
+		return true
+	case class == classCR || class == classLF || class == classControl:
+		return true
+	// GB6-GB8: Hangul syllables
+	case prevClass == classHangulL && (class == classHangulL || class == classHangulV || class == classHangulLV || class == classHangulLVT):
+		return false
+	case (prevClass == classHangulLV || prevClass == classHangulV) && (class == classHangulV || class == classHangulT):
+		return false
+	case (prevClass == classHangulLVT || prevClass == classHangulT) && class == classHangulT:
+		return false
+	// GB9, GB9a: extenders and spacing marks
+	case class == classExtend || class == classZWJ:
+		return false
+	// GB11: emoji ZWJ sequences
+	case prevClass == classZWJ && class == classPictographic && afterZWJ:
+		return false
+	// GB12, GB13: regional indicators pair up into flags
+	case prevClass == classRegionalIndicator && class == classRegionalIndicator:
+		return riCount%2 == 0
+	}
+	return true
+}
+
+func classify(r rune) graphemeClass {
+	switch {
+	case r == '\r':
+		return classCR
+	case r == '\n':
+		return classLF
+	case r == 0x200D:
+		return classZWJ
+	case r >= 0x1F1E6 && r <= 0x1F1FF:
+		return classRegionalIndicator
+	case isExtend(r):
+		return classExtend
+	case unicode.IsControl(r) || r == 0x2028 || r == 0x2029:
+		return classControl
+	case isPictographic(r):
+		return classPictographic
+	case (r >= 0x1100 && r <= 0x115F) || (r >= 0xA960 && r <= 0xA97C):
+		return classHangulL
+	case (r >= 0x1160 && r <= 0x11A7) || (r >= 0xD7B0 && r <= 0xD7C6):
+		return classHangulV
+	case (r >= 0x11A8 && r <= 0x11FF) || (r >= 0xD7CB && r <= 0xD7FB):
+		return classHangulT
+	case r >= 0xAC00 && r <= 0xD7A3:
+		// Every 28th precomposed syllable has no trailing consonant
+		if (r-0xAC00)%28 == 0 {
+			return classHangulLV
+		}
+		return classHangulLVT
+	}
+	return classOther
+}
+
+func isExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		(r >= 0xFE00 && r <= 0xFE0F) || // variation selectors
+		(r >= 0x1F3FB && r <= 0x1F3FF) || // emoji skin tone modifiers
+		(r >= 0xE0020 && r <= 0xE007F) || // tags, used by subdivision flags
+		(r >= 0xE0100 && r <= 0xE01EF) // variation selectors supplement
+}
+
+// isPictographic approximates the Extended_Pictographic property
+func isPictographic(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF:
+		return true
+	case r >= 0x2600 && r <= 0x27BF:
+		return true
+	case r >= 0x2300 && r <= 0x23FF:
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF:
+		return true
+	}
+	switch r {
+	case 0x00A9, 0x00AE, 0x203C, 0x2049, 0x2122, 0x2139, 0x3030, 0x303D, 0x3297, 0x3299:
+		return true
+	}
+	return false
+}
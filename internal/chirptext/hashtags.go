@@ -0,0 +1,28 @@
+package chirptext
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var hashtagRegex = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_]+)`)
+
+// Hashtags returns the distinct hashtags in a chirp body, lowercased and
+// without the leading #, in the order they first appear. Tags made up of
+// digits only, such as "#1", and anchors inside URLs are not hashtags.
+func Hashtags(body string) []string {
+	body = urlRegex.ReplaceAllString(body, " ")
+
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, match := range hashtagRegex.FindAllStringSubmatch(body, -1) {
+		tag := strings.ToLower(match[1])
+		if seen[tag] || strings.IndexFunc(tag, unicode.IsLetter) < 0 {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
@@ -0,0 +1,17 @@
+package chirptext
+
+import "regexp"
+
+var urlRegex = regexp.MustCompile(`(?i)\bhttps?://[^\s]+`)
+
+// Length is the weighted length of a chirp: user-perceived characters,
+// except that every URL counts as urlWeight however long it is
+func Length(body string, urlWeight int) int {
+	length := 0
+	last := 0
+	for _, match := range urlRegex.FindAllStringIndex(body, -1) {
+		length += Graphemes(body[last:match[0]]) + urlWeight
+		last = match[1]
+	}
+	return length + Graphemes(body[last:])
+}
@@ -7,29 +7,67 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const chirpExists = `-- name: ChirpExists :one
+-- Whether the user already has this chirp, trashed ones included, for
+-- imports to skip
+SELECT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE user_id = $1 AND created_at = $2 AND body = $3
+)
+`
+
+type ChirpExistsParams struct {
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	Body      string
+}
+
+func (q *Queries) ChirpExists(ctx context.Context, arg ChirpExistsParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, chirpExists, arg.UserID, arg.CreatedAt, arg.Body)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, content_warning, sensitive, visibility)
 VALUES (
     gen_random_uuid(),
+    COALESCE($1::timestamp, NOW()),
     NOW(),
-    NOW(),
-    $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, deleted_by, content_warning, sensitive, visibility
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	CreatedAt      sql.NullTime
+	Body           string
+	UserID         uuid.UUID
+	ContentWarning string
+	Sensitive      bool
+	Visibility     string
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.CreatedAt,
+		arg.Body,
+		arg.UserID,
+		arg.ContentWarning,
+		arg.Sensitive,
+		arg.Visibility,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,23 +75,40 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.Visibility,
 	)
 	return i, err
 }
 
-const deleteChirp = `-- name: DeleteChirp :exec
-DELETE FROM chirps
-WHERE id = $1
+const deleteChirp = `-- name: DeleteChirp :execrows
+-- With if_updated_at, only deletes the chirp if it hasn't changed since
+UPDATE chirps
+SET deleted_at = NOW(), deleted_by = $1, updated_at = NOW()
+WHERE id = $2 AND deleted_at IS NULL
+AND ($3::timestamp IS NULL OR updated_at = $3)
 `
 
-func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteChirp, id)
-	return err
+type DeleteChirpParams struct {
+	DeletedBy   uuid.NullUUID
+	ID          uuid.UUID
+	IfUpdatedAt sql.NullTime
+}
+
+func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteChirp, arg.DeletedBy, arg.ID, arg.IfUpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id from chirps
-WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, deleted_at, deleted_by, content_warning, sensitive, visibility from chirps
+WHERE id = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -65,17 +120,47 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.Visibility,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id from chirps
+SELECT id, created_at, updated_at, body, user_id, deleted_at, deleted_by, content_warning, sensitive, visibility from chirps
+WHERE chirps.deleted_at IS NULL
+AND chirps.user_id NOT IN (
+    SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = $1
+    UNION
+    SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = $1
+    UNION
+    SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = $1
+)
+AND (
+    chirps.visibility = 'public'
+    OR chirps.user_id = $1
+    OR EXISTS (
+        SELECT 1 FROM chirp_mentions
+        WHERE chirp_mentions.chirp_id = chirps.id
+        AND chirp_mentions.user_id = $1
+    )
+    OR (
+        chirps.visibility = 'followers'
+        AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $1
+            AND follows.followee_id = chirps.user_id
+        )
+    )
+)
 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+func (q *Queries) GetChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -89,6 +174,11 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.Visibility,
 		); err != nil {
 			return nil, err
 		}
@@ -104,12 +194,159 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsFromId = `-- name: GetChirpsFromId :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, deleted_at, deleted_by, content_warning, sensitive, visibility FROM chirps
+WHERE user_id = $1
+AND chirps.deleted_at IS NULL
+AND chirps.user_id NOT IN (
+    SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = $2
+    UNION
+    SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = $2
+    UNION
+    SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = $2
+)
+AND (
+    chirps.visibility = 'public'
+    OR chirps.user_id = $2
+    OR EXISTS (
+        SELECT 1 FROM chirp_mentions
+        WHERE chirp_mentions.chirp_id = chirps.id
+        AND chirp_mentions.user_id = $2
+    )
+    OR (
+        chirps.visibility = 'followers'
+        AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $2
+            AND follows.followee_id = chirps.user_id
+        )
+    )
+)
+`
+
+type GetChirpsFromIdParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetChirpsFromId(ctx context.Context, arg GetChirpsFromIdParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsFromId, arg.UserID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsLastModified = `-- name: GetChirpsLastModified :one
+-- Poll votes don't touch their chirp, so they count separately
+SELECT GREATEST(
+    COALESCE((SELECT MAX(updated_at) FROM chirps), '1970-01-01'),
+    COALESCE((SELECT MAX(created_at) FROM poll_votes), '1970-01-01')
+)::timestamp AS last_modified
+`
+
+func (q *Queries) GetChirpsLastModified(ctx context.Context) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getChirpsLastModified)
+	var last_modified time.Time
+	err := row.Scan(&last_modified)
+	return last_modified, err
+}
+
+const getTrashedChirps = `-- name: GetTrashedChirps :many
+SELECT id, created_at, updated_at, body, user_id, deleted_at, deleted_by, content_warning, sensitive, visibility FROM chirps
+WHERE user_id = $1
+AND deleted_by = $1
+AND deleted_at > $2::timestamp
+ORDER BY deleted_at DESC
+`
+
+type GetTrashedChirpsParams struct {
+	UserID       uuid.UUID
+	DeletedAfter time.Time
+}
+
+func (q *Queries) GetTrashedChirps(ctx context.Context, arg GetTrashedChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getTrashedChirps, arg.UserID, arg.DeletedAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserChirpsAfter = `-- name: GetUserChirpsAfter :many
+SELECT id, created_at, updated_at, body, user_id, deleted_at, deleted_by, content_warning, sensitive, visibility FROM chirps
 WHERE user_id = $1
+AND deleted_at IS NULL
+AND (created_at, id) > ($2::timestamp, $3::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT $4
 `
 
-func (q *Queries) GetChirpsFromId(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsFromId, userID)
+type GetUserChirpsAfterParams struct {
+	UserID         uuid.UUID
+	AfterCreatedAt time.Time
+	AfterID        uuid.UUID
+	MaxResults     int32
+}
+
+func (q *Queries) GetUserChirpsAfter(ctx context.Context, arg GetUserChirpsAfterParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getUserChirpsAfter,
+		arg.UserID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.MaxResults,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -123,6 +360,11 @@ func (q *Queries) GetChirpsFromId(ctx context.Context, userID uuid.UUID) ([]Chir
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.Visibility,
 		); err != nil {
 			return nil, err
 		}
@@ -136,3 +378,164 @@ func (q *Queries) GetChirpsFromId(ctx context.Context, userID uuid.UUID) ([]Chir
 	}
 	return items, nil
 }
+
+const getVisibleChirp = `-- name: GetVisibleChirp :one
+SELECT id, created_at, updated_at, body, user_id, deleted_at, deleted_by, content_warning, sensitive, visibility from chirps
+WHERE chirps.id = $1
+AND chirps.deleted_at IS NULL
+AND (
+    chirps.visibility = 'public'
+    OR chirps.user_id = $2
+    OR EXISTS (
+        SELECT 1 FROM chirp_mentions
+        WHERE chirp_mentions.chirp_id = chirps.id
+        AND chirp_mentions.user_id = $2
+    )
+    OR (
+        chirps.visibility = 'followers'
+        AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $2
+            AND follows.followee_id = chirps.user_id
+        )
+    )
+)
+`
+
+type GetVisibleChirpParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetVisibleChirp(ctx context.Context, arg GetVisibleChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getVisibleChirp, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const isChirpVisible = `-- name: IsChirpVisible :one
+SELECT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE chirps.id = $1
+    AND chirps.user_id NOT IN (
+        SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = $2
+        UNION
+        SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = $2
+        UNION
+        SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = $2
+    )
+    AND (
+        chirps.visibility = 'public'
+        OR chirps.user_id = $2
+        OR EXISTS (
+            SELECT 1 FROM chirp_mentions
+            WHERE chirp_mentions.chirp_id = chirps.id
+            AND chirp_mentions.user_id = $2
+        )
+        OR (
+            chirps.visibility = 'followers'
+            AND EXISTS (
+                SELECT 1 FROM follows
+                WHERE follows.follower_id = $2
+                AND follows.followee_id = chirps.user_id
+            )
+        )
+    )
+)
+`
+
+type IsChirpVisibleParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) IsChirpVisible(ctx context.Context, arg IsChirpVisibleParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isChirpVisible, arg.ID, arg.ViewerID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at <= $1::timestamp
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, purgeBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, purgeBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, deleted_by = NULL, updated_at = NOW()
+WHERE id = $1
+AND user_id = $2
+AND deleted_by = $2
+AND deleted_at > $3::timestamp
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, deleted_by, content_warning, sensitive, visibility
+`
+
+type RestoreChirpParams struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	DeletedAfter time.Time
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, arg.ID, arg.UserID, arg.DeletedAfter)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const touchChirp = `-- name: TouchChirp :one
+UPDATE chirps
+SET updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, body, user_id, deleted_at, deleted_by, content_warning, sensitive, visibility
+`
+
+func (q *Queries) TouchChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, touchChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.Visibility,
+	)
+	return i, err
+}
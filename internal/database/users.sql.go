@@ -37,7 +37,7 @@ VALUES (
     $1,
     $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_admin, is_moderator, suspended_at, expand_sensitive
 `
 
 type CreateUserParams struct {
@@ -54,13 +54,26 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.IsModerator,
+		&i.SuspendedAt,
+		&i.ExpandSensitive,
 	)
 	return i, err
 }
 
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUser, id)
+	return err
+}
+
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_admin, is_moderator, suspended_at, expand_sensitive FROM users
 WHERE id = $1
 `
 
@@ -73,13 +86,29 @@ func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.IsModerator,
+		&i.SuspendedAt,
+		&i.ExpandSensitive,
 	)
 	return i, err
 }
 
+const lockUser = `-- name: LockUser :exec
+-- Serializes a user's limit checks with their inserts. NO KEY UPDATE
+-- doesn't hold up inserts that only reference the user.
+SELECT id FROM users
+WHERE id = $1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) LockUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockUser, id)
+	return err
+}
+
 const loginUser = `-- name: LoginUser :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_admin, is_moderator, suspended_at, expand_sensitive FROM users
 WHERE email = $1
 `
 
@@ -92,7 +121,10 @@ func (q *Queries) LoginUser(ctx context.Context, email string) (User, error) {
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.IsAdmin,
+		&i.IsModerator,
+		&i.SuspendedAt,
+		&i.ExpandSensitive,
 	)
 	return i, err
 }
@@ -106,13 +138,43 @@ func (q *Queries) ResetUsers(ctx context.Context) error {
 	return err
 }
 
-const upgradeChirpyRed = `-- name: UpgradeChirpyRed :exec
+const setExpandSensitive = `-- name: SetExpandSensitive :exec
 UPDATE users
-SET is_chirpy_red = TRUE
+SET expand_sensitive = $2, updated_at = NOW()
 WHERE id = $1
 `
 
-func (q *Queries) UpgradeChirpyRed(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeChirpyRed, id)
+type SetExpandSensitiveParams struct {
+	ID              uuid.UUID
+	ExpandSensitive bool
+}
+
+func (q *Queries) SetExpandSensitive(ctx context.Context, arg SetExpandSensitiveParams) error {
+	_, err := q.db.ExecContext(ctx, setExpandSensitive, arg.ID, arg.ExpandSensitive)
 	return err
 }
+
+const suspendUser = `-- name: SuspendUser :exec
+UPDATE users
+SET suspended_at = NOW()
+WHERE id = $1 AND suspended_at IS NULL
+`
+
+func (q *Queries) SuspendUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, suspendUser, id)
+	return err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :execrows
+UPDATE users
+SET suspended_at = NULL
+WHERE id = $1 AND suspended_at IS NOT NULL
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unsuspendUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,170 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	KindChirpCreated = "chirp.created"
+	KindChirpUpdated = "chirp.updated"
+	KindChirpDeleted = "chirp.deleted"
+
+	KindNotificationCreated = "notification.created"
+
+	KindUserSuspended = "user.suspended"
+)
+
+const (
+	// subscriberQueueSize is how many events a subscriber may fall behind
+	// before it is dropped and has to resume with Last-Event-ID
+	subscriberQueueSize = 64
+)
+
+// Event tells subscribers that something happened to a chirp, that a user
+// got a notification, or that a user was suspended. It carries just
+// enough to filter on, subscribers load the chirp or notification itself
+// so that each one only ever sees what it is allowed to.
+type Event struct {
+	ID       string    `json:"id"`
+	Kind     string    `json:"kind"`
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	AuthorID uuid.UUID `json:"author_id"`
+	Hashtags []string  `json:"hashtags"`
+
+	// Set for notification and user events only
+	UserID         uuid.UUID `json:"user_id,omitzero"`
+	NotificationID uuid.UUID `json:"notification_id,omitzero"`
+}
+
+func (e Event) IsChirpEvent() bool {
+	return strings.HasPrefix(e.Kind, "chirp.")
+}
+
+// Suspends reports whether event is the suspension of userID, whose live
+// connections must be closed
+func (e Event) Suspends(userID uuid.UUID) bool {
+	return e.Kind == KindUserSuspended && userID != uuid.Nil && e.UserID == userID
+}
+
+// Subscription receives events until it is unsubscribed or dropped, at
+// which point C is closed
+type Subscription struct {
+	C <-chan Event
+	c chan Event
+}
+
+// Hub fans chirp events out to subscribers on every replica. Events are
+// published with Postgres NOTIFY and delivered by each replica's listener,
+// so all replicas see them in the same order. The last bufferSize events
+// are kept for subscribers resuming after a disconnect.
+type Hub struct {
+	db         *database.Queries
+	bufferSize int
+
+	mu          sync.Mutex
+	buffer      []Event
+	subscribers map[*Subscription]bool
+}
+
+func NewHub(db *database.Queries, bufferSize int) *Hub {
+	return &Hub{
+		db:          db,
+		bufferSize:  bufferSize,
+		subscribers: map[*Subscription]bool{},
+	}
+}
+
+// Publish sends event to the subscribers of every replica, this one
+// included. Its ID is assigned here.
+func (h *Hub) Publish(ctx context.Context, event Event) error {
+	return h.PublishWith(ctx, h.db, event)
+}
+
+// PublishWith is Publish through db, which may be bound to a transaction.
+// Postgres holds back a transaction's notifications until it commits, so
+// subscribers never hear of rows they can't read yet.
+func (h *Hub) PublishWith(ctx context.Context, db *database.Queries, event Event) error {
+	event.ID = uuid.NewString()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return db.NotifyEvent(ctx, database.NotifyEventParams{
+		Channel: channel,
+		Payload: string(payload),
+	})
+}
+
+// Subscribe registers a new subscriber. With a lastEventID it also returns
+// the buffered events that came after it; ok is false when that event is
+// no longer buffered, so the subscriber can't know what it missed.
+func (h *Hub) Subscribe(lastEventID string) (sub *Subscription, backlog []Event, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := make(chan Event, subscriberQueueSize)
+	sub = &Subscription{C: c, c: c}
+	h.subscribers[sub] = true
+
+	if lastEventID == "" {
+		return sub, nil, true
+	}
+	for i, event := range h.buffer {
+		if event.ID == lastEventID {
+			return sub, append([]Event(nil), h.buffer[i+1:]...), true
+		}
+	}
+	return sub, nil, false
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscribers[sub] {
+		delete(h.subscribers, sub)
+		close(sub.c)
+	}
+}
+
+// deliver buffers event and hands it to every subscriber. Subscribers too
+// far behind are dropped rather than allowed to hold everyone up.
+func (h *Hub) deliver(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buffer = append(h.buffer, event)
+	if len(h.buffer) > h.bufferSize {
+		h.buffer = h.buffer[len(h.buffer)-h.bufferSize:]
+	}
+
+	for sub := range h.subscribers {
+		select {
+		case sub.c <- event:
+		default:
+			delete(h.subscribers, sub)
+			close(sub.c)
+		}
+	}
+}
+
+// reset forgets the buffer and drops every subscriber, for when events
+// may have been lost. Subscribers reconnecting can then tell they missed
+// something because their last event is gone.
+func (h *Hub) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buffer = nil
+	for sub := range h.subscribers {
+		delete(h.subscribers, sub)
+		close(sub.c)
+	}
+}
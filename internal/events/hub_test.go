@@ -0,0 +1,102 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDeliverReachesSubscribers(t *testing.T) {
+	hub := NewHub(nil, 10)
+	sub, backlog, ok := hub.Subscribe("")
+	if !ok || len(backlog) != 0 {
+		t.Fatalf("fresh subscription must start empty, got %v %v", backlog, ok)
+	}
+
+	hub.deliver(Event{ID: "1"})
+	hub.deliver(Event{ID: "2"})
+
+	for _, expected := range []string{"1", "2"} {
+		if event := <-sub.C; event.ID != expected {
+			t.Errorf("expected event %s, got %s", expected, event.ID)
+		}
+	}
+
+	hub.Unsubscribe(sub)
+	if _, open := <-sub.C; open {
+		t.Error("unsubscribing must close the channel")
+	}
+	hub.Unsubscribe(sub)
+}
+
+func TestSubscribeResumesFromBuffer(t *testing.T) {
+	hub := NewHub(nil, 3)
+	for _, id := range []string{"1", "2", "3", "4"} {
+		hub.deliver(Event{ID: id})
+	}
+
+	_, backlog, ok := hub.Subscribe("2")
+	if !ok || len(backlog) != 2 || backlog[0].ID != "3" || backlog[1].ID != "4" {
+		t.Errorf("expected events 3 and 4 after 2, got %v %v", backlog, ok)
+	}
+
+	_, backlog, ok = hub.Subscribe("4")
+	if !ok || len(backlog) != 0 {
+		t.Errorf("expected nothing after the latest event, got %v %v", backlog, ok)
+	}
+
+	// Event 1 fell out of the buffer
+	if _, _, ok := hub.Subscribe("1"); ok {
+		t.Error("resuming from an evicted event must not be ok")
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	hub := NewHub(nil, 10)
+	slow, _, _ := hub.Subscribe("")
+
+	for range subscriberQueueSize + 1 {
+		hub.deliver(Event{ID: "x"})
+	}
+
+	received := 0
+	for range slow.C {
+		received++
+	}
+	if received != subscriberQueueSize {
+		t.Errorf("expected %d queued events before the drop, got %d", subscriberQueueSize, received)
+	}
+}
+
+func TestResetDropsBufferAndSubscribers(t *testing.T) {
+	hub := NewHub(nil, 10)
+	hub.deliver(Event{ID: "1"})
+	sub, _, _ := hub.Subscribe("")
+
+	hub.reset()
+
+	if _, open := <-sub.C; open {
+		t.Error("reset must close subscriptions")
+	}
+	if _, _, ok := hub.Subscribe("1"); ok {
+		t.Error("reset must forget buffered events")
+	}
+}
+
+func TestSuspends(t *testing.T) {
+	userID := uuid.New()
+	event := Event{Kind: KindUserSuspended, UserID: userID}
+
+	if !event.Suspends(userID) {
+		t.Error("suspension must apply to its user")
+	}
+	if event.Suspends(uuid.New()) {
+		t.Error("suspension must not apply to other users")
+	}
+	if (Event{Kind: KindUserSuspended}).Suspends(uuid.Nil) {
+		t.Error("suspension must not apply to anonymous viewers")
+	}
+	if (Event{Kind: KindNotificationCreated, UserID: userID}).Suspends(userID) {
+		t.Error("only suspension events suspend")
+	}
+}
@@ -0,0 +1,71 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// channel is the Postgres NOTIFY channel events travel on
+	channel = "chirp_events"
+
+	minReconnectInterval = 10 * time.Second
+	maxReconnectInterval = time.Minute
+	// pingInterval keeps an idle listener connection checked, so a dead
+	// one is noticed and replaced
+	pingInterval = 90 * time.Second
+)
+
+// Start listens for events published by any replica and delivers them to
+// this replica's subscribers until ctx is done. It fails only if it can't
+// start listening at all; later connection problems are retried.
+func (h *Hub) Start(ctx context.Context, dbURL string) error {
+	listener := pq.NewListener(dbURL, minReconnectInterval, maxReconnectInterval, func(_ pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("event listener: %v\n", err)
+		}
+	})
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return err
+	}
+
+	go h.listen(ctx, listener)
+	return nil
+}
+
+func (h *Hub) listen(ctx context.Context, listener *pq.Listener) {
+	defer listener.Close()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-listener.Notify:
+			// A nil notification means the connection was re-established,
+			// anything sent in the meantime is lost
+			if notification == nil {
+				h.reset()
+				continue
+			}
+
+			event := Event{}
+			if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+				log.Printf("event listener: %v\n", err)
+				continue
+			}
+			h.deliver(event)
+		case <-ticker.C:
+			if err := listener.Ping(); err != nil {
+				log.Printf("event listener: %v\n", err)
+			}
+		}
+	}
+}
@@ -4,11 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -17,15 +17,30 @@ import (
 
 	"github.com/dmitriy-zverev/chirpy/internal/auth"
 	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/dmitriy-zverev/chirpy/internal/events"
+	"github.com/dmitriy-zverev/chirpy/internal/moderation"
+	"github.com/dmitriy-zverev/chirpy/internal/storage"
+	"github.com/dmitriy-zverev/chirpy/internal/timeline"
 	"github.com/google/uuid"
 )
 
 type ApiConfig struct {
 	FileserverHits atomic.Int32
+	DB             *sql.DB
 	DbQueries      *database.Queries
 	Platform       string
 	JWTSecret      []byte
 	PolkaKey       []byte
+	PolkaSecrets   [][]byte
+	Fanout         *timeline.Fanout
+	Blobs          storage.BlobStore
+	Moderation     *moderation.Engine
+	Events         *events.Hub
+
+	ChirpMaxLength    int
+	ChirpMaxLengthRed int
+
+	PolkaSignatureTolerance time.Duration
 }
 
 func (cfg *ApiConfig) MiddlewareMetricsInc(next http.Handler) http.Handler {
@@ -103,6 +118,13 @@ func (cfg *ApiConfig) UsersHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	isRed, err := cfg.isChirpyRed(context.Background(), user.ID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
 	resp, err := json.Marshal(
 		struct {
 			Id          string `json:"id"`
@@ -115,7 +137,7 @@ func (cfg *ApiConfig) UsersHandler(w http.ResponseWriter, req *http.Request) {
 			Created_at:  user.CreatedAt.String(),
 			Updated_at:  user.UpdatedAt.String(),
 			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed.Bool,
+			IsChirpyRed: isRed,
 		},
 	)
 	if err != nil {
@@ -130,11 +152,7 @@ func (cfg *ApiConfig) UsersHandler(w http.ResponseWriter, req *http.Request) {
 }
 
 func (cfg *ApiConfig) ChirpsPostHandler(w http.ResponseWriter, req *http.Request) {
-	type parameters struct {
-		Body string `json:"body"`
-	}
-
-	params := parameters{}
+	params := newChirp{}
 	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
 		log.Printf("%v\n", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -155,81 +173,74 @@ func (cfg *ApiConfig) ChirpsPostHandler(w http.ResponseWriter, req *http.Request
 		return
 	}
 
-	if len(params.Body) > 140 {
-		type returnVals struct {
-			Error string `json:"error"`
-		}
+	tx, err := cfg.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
 
-		respBody := returnVals{
-			Error: "Chirp is too long",
+	chirp, err := cfg.createChirp(context.Background(), cfg.DbQueries.WithTx(tx), userId, params)
+	if err != nil {
+		var validationErr *chirpValidationError
+		if errors.As(err, &validationErr) {
+			respondWithChirpValidationError(w, http.StatusBadRequest, validationErr)
+			return
 		}
-		dat, err := json.Marshal(respBody)
-		if err != nil {
-			log.Printf("%v\n", err)
-			w.WriteHeader(http.StatusInternalServerError)
+		var heldErr *chirpHeldError
+		if errors.As(err, &heldErr) {
+			if err := tx.Commit(); err != nil {
+				log.Printf("%v\n", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			respondWithJSON(w, http.StatusAccepted, struct {
+				Id     string `json:"id"`
+				Status string `json:"status"`
+			}{
+				Id:     heldErr.ID.String(),
+				Status: "held_for_review",
+			})
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(dat)
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	splittedBody := strings.Split(params.Body, " ")
-	profoundWords := []string{
-		"kerfuffle",
-		"sharbert",
-		"fornax",
-	}
-
-	for i, word := range splittedBody {
-		if slices.Contains(profoundWords, strings.ToLower(word)) {
-			splittedBody[i] = "****"
-		}
-	}
-
-	createChirpParams := database.CreateChirpParams{
-		Body:   strings.Join(splittedBody, " "),
-		UserID: userId,
-	}
-	chirp, err := cfg.DbQueries.CreateChirp(context.Background(), createChirpParams)
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		log.Printf("%v\n", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	dat, err := json.Marshal(
-		struct {
-			Id        string    `json:"id"`
-			CreatedAt string    `json:"created_at"`
-			UpdatedAt string    `json:"updated_at"`
-			Body      string    `json:"body"`
-			UserID    uuid.UUID `json:"user_id"`
-		}{
-			Id:        chirp.ID.String(),
-			CreatedAt: chirp.CreatedAt.String(),
-			UpdatedAt: chirp.UpdatedAt.String(),
-			Body:      chirp.Body,
-			UserID:    userId,
-		},
-	)
+	cfg.Fanout.Enqueue(chirp)
+	cfg.publishChirpEvent(context.Background(), events.KindChirpCreated, chirp)
+
+	chirpResp, err := cfg.chirpJson(context.Background(), userId, chirp)
 	if err != nil {
-		log.Printf("Error marshalling JSON: %v\n", err)
+		log.Printf("%v\n", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	w.Write(dat)
+
+	respondWithJSON(w, http.StatusCreated, chirpResp)
 }
 
 func (cfg *ApiConfig) ChirpsGetHandler(w http.ResponseWriter, req *http.Request) {
 	authorId := req.URL.Query().Get("author_id")
 	sortValue := req.URL.Query().Get("sort")
 
+	viewerID, err := cfg.optionalAuthenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
 	var chirps []database.Chirp
-	var err error
+	var pinnedIDs []uuid.UUID
 
 	if authorId != "" {
 		userID, err := uuid.Parse(authorId)
@@ -239,14 +250,27 @@ func (cfg *ApiConfig) ChirpsGetHandler(w http.ResponseWriter, req *http.Request)
 			return
 		}
 
-		chirps, err = cfg.DbQueries.GetChirpsFromId(context.Background(), userID)
+		chirps, err = cfg.DbQueries.GetChirpsFromId(
+			context.Background(),
+			database.GetChirpsFromIdParams{
+				UserID:   userID,
+				ViewerID: viewerID,
+			},
+		)
+		if err != nil {
+			log.Printf("%v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		pinnedIDs, err = cfg.DbQueries.GetPinnedChirpIDs(context.Background(), userID)
 		if err != nil {
 			log.Printf("%v\n", err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 	} else {
-		chirps, err = cfg.DbQueries.GetChirps(context.Background())
+		chirps, err = cfg.DbQueries.GetChirps(context.Background(), viewerID)
 		if err != nil {
 			log.Printf("%v\n", err)
 			w.WriteHeader(http.StatusInternalServerError)
@@ -265,25 +289,51 @@ func (cfg *ApiConfig) ChirpsGetHandler(w http.ResponseWriter, req *http.Request)
 		})
 	}
 
-	type chirpJson struct {
-		Id        string `json:"id"`
-		CreatedAt string `json:"created_at"`
-		UpdatedAt string `json:"updated_at"`
-		Body      string `json:"body"`
-		UserID    string `json:"user_id"`
+	expandSensitive, err := cfg.expandsSensitive(context.Background(), viewerID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	polls, err := cfg.pollVersions(context.Background(), chirps)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Deleting a chirp bumps its updated_at too, so the newest chirp change
+	// or poll vote only moves forward
+	lastModified, err := cfg.DbQueries.GetChirpsLastModified(context.Background())
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
-	chirpsJsons := []chirpJson{}
+	// Pins, follows, blocks, mutes and the viewer's preferences change the
+	// listing without moving any date, so only the ETag can tell it apart
+	etag := chirpsETag(
+		viewerID,
+		expandSensitive,
+		fmt.Sprintf("author_id=%s sort=%s pinned=%v", authorId, sortValue, pinnedIDs),
+		chirps,
+		polls,
+	)
+	if etagNotModified(req, etag) {
+		writeNotModified(w, etag, lastModified)
+		return
+	}
 
-	for _, chirp := range chirps {
-		newChirpStruct := chirpJson{
-			Id:        chirp.ID.String(),
-			CreatedAt: chirp.CreatedAt.String(),
-			UpdatedAt: chirp.UpdatedAt.String(),
-			Body:      chirp.Body,
-			UserID:    chirp.UserID.String(),
-		}
-		chirpsJsons = append(chirpsJsons, newChirpStruct)
+	chirpsJsons, err := cfg.chirpJsons(context.Background(), viewerID, chirps)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if authorId != "" {
+		chirpsJsons = pinnedFirst(chirpsJsons, pinnedIDs)
 	}
 
 	dat, err := json.Marshal(chirpsJsons)
@@ -293,6 +343,7 @@ func (cfg *ApiConfig) ChirpsGetHandler(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	setValidators(w, etag, lastModified)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(dat)
@@ -306,36 +357,69 @@ func (cfg *ApiConfig) ChirpGetHandler(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
-	chirp, err := cfg.DbQueries.GetChirp(context.Background(), chirpId)
+	viewerID, err := cfg.optionalAuthenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	// Chirps the viewer may not see are reported as missing, not forbidden,
+	// so their existence isn't leaked
+	chirp, err := cfg.DbQueries.GetVisibleChirp(
+		context.Background(),
+		database.GetVisibleChirpParams{
+			ID:       chirpId,
+			ViewerID: viewerID,
+		},
+	)
 	if err != nil {
 		log.Printf("%v\n", err)
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	type chirpJson struct {
-		Id        string `json:"id"`
-		CreatedAt string `json:"created_at"`
-		UpdatedAt string `json:"updated_at"`
-		Body      string `json:"body"`
-		UserID    string `json:"user_id"`
+	blocked, err := cfg.isBlocked(context.Background(), viewerID, chirp.UserID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
 
-	dat, err := json.Marshal(
-		chirpJson{
-			Id:        chirp.ID.String(),
-			CreatedAt: chirp.CreatedAt.String(),
-			UpdatedAt: chirp.UpdatedAt.String(),
-			Body:      chirp.Body,
-			UserID:    chirp.UserID.String(),
-		},
-	)
+	polls, err := cfg.pollVersions(context.Background(), []database.Chirp{chirp})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// A single chirp is never collapsed, the preference doesn't matter
+	etag := chirpsETag(viewerID, false, "", []database.Chirp{chirp}, polls)
+	lastModified := chirpLastModified(chirp, polls)
+	if notModified(req, etag, lastModified) {
+		writeNotModified(w, etag, lastModified)
+		return
+	}
+
+	chirpResp, err := cfg.chirpJson(context.Background(), viewerID, chirp)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(chirpResp)
 	if err != nil {
 		log.Printf("%v\n", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	setValidators(w, etag, lastModified)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(dat)
@@ -368,6 +452,11 @@ func (cfg *ApiConfig) LoginHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if user.SuspendedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "Your account is suspended")
+		return
+	}
+
 	parsedDuration, err := time.ParseDuration(os.Getenv("JWT_EXPIRATION_TIME"))
 	if err != nil {
 		log.Printf("%v\n", err)
@@ -403,6 +492,13 @@ func (cfg *ApiConfig) LoginHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	isRed, err := cfg.isChirpyRed(context.Background(), user.ID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
 	resp, err := json.Marshal(
 		struct {
 			Id           string `json:"id"`
@@ -419,7 +515,7 @@ func (cfg *ApiConfig) LoginHandler(w http.ResponseWriter, req *http.Request) {
 			Email:        user.Email,
 			Token:        jwtToken,
 			RefreshToken: refreshToken,
-			IsChirpyRed:  user.IsChirpyRed.Bool,
+			IsChirpyRed:  isRed,
 		},
 	)
 	if err != nil {
@@ -463,6 +559,11 @@ func (cfg *ApiConfig) RefreshHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if user.SuspendedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "Your account is suspended")
+		return
+	}
+
 	parsedExpiresIn, err := time.ParseDuration("1h")
 	if err != nil {
 		log.Printf("%v\n", err)
@@ -568,6 +669,12 @@ func (cfg *ApiConfig) UsersPutHandler(w http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	isRed, err := cfg.isChirpyRed(context.Background(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
 	resp, err := json.Marshal(
 		struct {
 			Id          string `json:"id"`
@@ -580,7 +687,7 @@ func (cfg *ApiConfig) UsersPutHandler(w http.ResponseWriter, req *http.Request)
 			Email:       userRow.Email,
 			CreatedAt:   userRow.CreatedAt.String(),
 			UpdatedAt:   userRow.UpdatedAt.String(),
-			IsChirpyRed: userRow.IsChirpyRed.Bool,
+			IsChirpyRed: isRed,
 		},
 	)
 	if err != nil {
@@ -615,9 +722,12 @@ func (cfg *ApiConfig) ChirpDeleteHandler(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	chirpRow, err := cfg.DbQueries.GetChirp(
+	chirpRow, err := cfg.DbQueries.GetVisibleChirp(
 		context.Background(),
-		chirpId,
+		database.GetVisibleChirpParams{
+			ID:       chirpId,
+			ViewerID: userID,
+		},
 	)
 	if err != nil {
 		log.Printf("%v\n", err)
@@ -631,60 +741,55 @@ func (cfg *ApiConfig) ChirpDeleteHandler(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	if err := cfg.DbQueries.DeleteChirp(
-		context.Background(),
-		chirpId,
-	); err != nil {
+	polls, err := cfg.pollVersions(context.Background(), []database.Chirp{chirpRow})
+	if err != nil {
 		log.Printf("%v\n", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func (cfg *ApiConfig) PolkaHookPostHandler(w http.ResponseWriter, req *http.Request) {
-	apiKey, err := auth.GetAPIKey(req.Header)
-	if err != nil {
-		log.Printf("%v", err)
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	if apiKey != os.Getenv("POLKA_KEY") {
-		log.Printf("%v", err)
-		w.WriteHeader(http.StatusUnauthorized)
+	if preconditionFailed(req, chirpsETag(userID, false, "", []database.Chirp{chirpRow}, polls)) {
+		w.WriteHeader(http.StatusPreconditionFailed)
 		return
 	}
 
-	type parameters struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID uuid.UUID `json:"user_id"`
-		} `json:"data"`
+	// The delete only goes through if the chirp is still the one the ETag
+	// matched, a write in between gets the 412 too
+	ifUpdatedAt := sql.NullTime{}
+	if req.Header.Get("If-Match") != "" {
+		ifUpdatedAt = sql.NullTime{Time: chirpRow.UpdatedAt, Valid: true}
 	}
 
-	params := parameters{}
-	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
-		log.Printf("%v", err)
+	deleted, err := cfg.DbQueries.DeleteChirp(
+		context.Background(),
+		database.DeleteChirpParams{
+			ID:          chirpId,
+			DeletedBy:   uuid.NullUUID{UUID: userID, Valid: true},
+			IfUpdatedAt: ifUpdatedAt,
+		},
+	)
+	if err != nil {
+		log.Printf("%v\n", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-
-	if params.Event != POLKA_WEBHOOK_EVENT {
-		log.Printf("unsupported event type")
-		w.WriteHeader(http.StatusNoContent)
+	if deleted == 0 {
+		if ifUpdatedAt.Valid {
+			w.WriteHeader(http.StatusPreconditionFailed)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
 		return
 	}
 
-	if err := cfg.DbQueries.UpgradeChirpyRed(
+	if err := cfg.DbQueries.TombstoneBookmarks(
 		context.Background(),
-		params.Data.UserID,
+		chirpId,
 	); err != nil {
-		log.Printf("%v", err)
-		w.WriteHeader(http.StatusNotFound)
-		return
+		log.Printf("%v\n", err)
 	}
+	cfg.publishChirpEvent(context.Background(), events.KindChirpDeleted, chirpRow)
+	cfg.enqueueChirpDeletedWebhook(context.Background(), chirpRow)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/dmitriy-zverev/chirpy/internal/events"
+	"github.com/google/uuid"
+)
+
+// archivedChirp is one line of an NDJSON export, and what an import
+// accepts per line. Exports can be imported as they are, the ID is ignored.
+type archivedChirp struct {
+	Id             string    `json:"id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	Body           string    `json:"body"`
+	ContentWarning string    `json:"content_warning"`
+	Sensitive      bool      `json:"sensitive"`
+	Visibility     string    `json:"visibility"`
+}
+
+func (cfg *ApiConfig) ChirpsExportHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="chirps.ndjson"`)
+	w.WriteHeader(http.StatusOK)
+
+	// Page through the chirps so only one page is ever held in memory,
+	// flushing after each one
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	afterCreatedAt, afterID := time.Time{}, uuid.Nil
+	for {
+		chirps, err := cfg.DbQueries.GetUserChirpsAfter(req.Context(), database.GetUserChirpsAfterParams{
+			UserID:         userID,
+			AfterCreatedAt: afterCreatedAt,
+			AfterID:        afterID,
+			MaxResults:     EXPORT_PAGE_SIZE,
+		})
+		if err != nil {
+			// Headers are gone, all we can do is cut the stream short
+			log.Printf("%v\n", err)
+			return
+		}
+
+		for _, chirp := range chirps {
+			if err := encoder.Encode(archivedChirp{
+				Id:             chirp.ID.String(),
+				CreatedAt:      chirp.CreatedAt,
+				Body:           chirp.Body,
+				ContentWarning: chirp.ContentWarning,
+				Sensitive:      chirp.Sensitive,
+				Visibility:     chirp.Visibility,
+			}); err != nil {
+				log.Printf("%v\n", err)
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if len(chirps) < EXPORT_PAGE_SIZE {
+			return
+		}
+		last := chirps[len(chirps)-1]
+		afterCreatedAt, afterID = last.CreatedAt, last.ID
+	}
+}
+
+type importLineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type importReport struct {
+	Imported int               `json:"imported"`
+	Held     int               `json:"held"`
+	Skipped  int               `json:"skipped"`
+	Failed   int               `json:"failed"`
+	Errors   []importLineError `json:"errors"`
+
+	// Set when a server error stopped the import partway, nothing from
+	// ResumeLine on was imported
+	Stopped    bool `json:"stopped"`
+	ResumeLine int  `json:"resume_line,omitempty"`
+}
+
+func (r *importReport) fail(line int, format string, args ...any) {
+	r.Failed++
+	r.Errors = append(r.Errors, importLineError{Line: line, Error: fmt.Sprintf(format, args...)})
+}
+
+func (r *importReport) merge(batch importReport) {
+	r.Imported += batch.Imported
+	r.Held += batch.Held
+	r.Skipped += batch.Skipped
+	r.Failed += batch.Failed
+	r.Errors = append(r.Errors, batch.Errors...)
+}
+
+// chirpImport writes imported chirps in transactions of IMPORT_BATCH_SIZE
+// lines. Each line runs in a savepoint, so a line that fails validation
+// halfway doesn't leave anything behind in its batch. The report only
+// takes in a batch once it is committed, so it stays true when a later
+// batch fails.
+type chirpImport struct {
+	cfg     *ApiConfig
+	userID  uuid.UUID
+	tx      *sql.Tx
+	lines   int
+	created []database.Chirp
+
+	report        importReport
+	batch         importReport
+	committedLine int
+}
+
+func (imp *chirpImport) add(ctx context.Context, line int, input newChirp) error {
+	if imp.tx == nil {
+		tx, err := imp.cfg.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		imp.tx = tx
+
+		// Concurrent imports of the same archive take turns, so they
+		// can't both miss a chirp the other is about to insert
+		if err := imp.cfg.DbQueries.WithTx(tx).LockUser(ctx, imp.userID); err != nil {
+			return err
+		}
+	}
+	qtx := imp.cfg.DbQueries.WithTx(imp.tx)
+
+	// Chirps already there are skipped, so importing an export twice or
+	// retrying an import that stopped partway doesn't duplicate them.
+	// Chirps without a timestamp can't be told apart.
+	if !input.CreatedAt.IsZero() {
+		exists, err := imp.exists(ctx, qtx, input)
+		if err != nil {
+			return err
+		}
+		if exists {
+			imp.batch.Skipped++
+			return imp.next(line)
+		}
+	}
+
+	if _, err := imp.tx.ExecContext(ctx, "SAVEPOINT import_line"); err != nil {
+		return err
+	}
+
+	chirp, err := imp.cfg.createChirp(ctx, qtx, imp.userID, input)
+	var validationErr *chirpValidationError
+	var heldErr *chirpHeldError
+	switch {
+	case errors.As(err, &validationErr):
+		if _, err := imp.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT import_line"); err != nil {
+			return err
+		}
+		imp.batch.fail(line, "%s", validationErr.Message)
+	case errors.As(err, &heldErr):
+		imp.batch.Held++
+	case err != nil:
+		return err
+	default:
+		imp.batch.Imported++
+		imp.created = append(imp.created, chirp)
+	}
+
+	return imp.next(line)
+}
+
+// exists reports whether the user already has input, either published
+// or held for review. Published chirps are stored with their body masked,
+// held ones as submitted.
+func (imp *chirpImport) exists(ctx context.Context, q *database.Queries, input newChirp) (bool, error) {
+	moderated, _ := imp.cfg.moderateChirp(input)
+	exists, err := q.ChirpExists(ctx, database.ChirpExistsParams{
+		UserID:    imp.userID,
+		CreatedAt: input.CreatedAt,
+		Body:      moderated.Body,
+	})
+	if err != nil || exists {
+		return exists, err
+	}
+
+	return q.HeldChirpExists(ctx, database.HeldChirpExistsParams{
+		UserID:    imp.userID,
+		Body:      input.Body,
+		CreatedAt: input.CreatedAt,
+	})
+}
+
+// next counts line towards the batch, committing it once it is full
+func (imp *chirpImport) next(line int) error {
+	imp.lines++
+	if imp.lines == IMPORT_BATCH_SIZE {
+		return imp.commit(line)
+	}
+	return nil
+}
+
+// commit commits the batch, which ends at line
+func (imp *chirpImport) commit(line int) error {
+	if imp.tx != nil {
+		err := imp.tx.Commit()
+		imp.tx = nil
+		imp.lines = 0
+		if err != nil {
+			return err
+		}
+	}
+
+	imp.report.merge(imp.batch)
+	imp.batch = importReport{}
+	imp.committedLine = line
+
+	for _, chirp := range imp.created {
+		imp.cfg.Fanout.Enqueue(chirp)
+		imp.cfg.publishChirpEvent(context.Background(), events.KindChirpCreated, chirp)
+	}
+	imp.created = nil
+	return nil
+}
+
+// stop gives up on the batch in progress and reports what was committed
+// before it
+func (imp *chirpImport) stop() importReport {
+	imp.rollback()
+	imp.report.Stopped = true
+	imp.report.ResumeLine = imp.committedLine + 1
+	return imp.report
+}
+
+func (imp *chirpImport) rollback() {
+	if imp.tx != nil {
+		imp.tx.Rollback()
+		imp.tx = nil
+	}
+}
+
+// ChirpsImportHandler answers a server error with the report so far, its
+// stopped flag set, so the client knows which line to resume from
+func (cfg *ApiConfig) ChirpsImportHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	imp := &chirpImport{
+		cfg:    cfg,
+		userID: userID,
+		report: importReport{Errors: []importLineError{}},
+	}
+	defer imp.rollback()
+
+	scanner := bufio.NewScanner(http.MaxBytesReader(w, req.Body, MAX_IMPORT_BYTES))
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), MAX_IMPORT_LINE_BYTES)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		item := archivedChirp{}
+		if err := json.Unmarshal(text, &item); err != nil {
+			imp.batch.fail(line, "Invalid JSON: %v", err)
+			continue
+		}
+		if item.CreatedAt.After(time.Now().UTC()) {
+			imp.batch.fail(line, "created_at is in the future")
+			continue
+		}
+
+		if err := imp.add(context.Background(), line, newChirp{
+			Body:           item.Body,
+			ContentWarning: item.ContentWarning,
+			Sensitive:      item.Sensitive,
+			Visibility:     item.Visibility,
+			CreatedAt:      item.CreatedAt.UTC(),
+		}); err != nil {
+			log.Printf("%v\n", err)
+			respondWithJSON(w, http.StatusInternalServerError, imp.stop())
+			return
+		}
+	}
+
+	// A line too long or a body too large ends the import, what was read
+	// until then still goes in
+	if err := scanner.Err(); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			imp.batch.fail(line+1, "Import is larger than %d MB, the rest was skipped", MAX_IMPORT_BYTES>>20)
+		case errors.Is(err, bufio.ErrTooLong):
+			imp.batch.fail(line+1, "Line is longer than %d KB, it and the rest were skipped", MAX_IMPORT_LINE_BYTES>>10)
+		default:
+			log.Printf("%v\n", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := imp.commit(line); err != nil {
+		log.Printf("%v\n", err)
+		respondWithJSON(w, http.StatusInternalServerError, imp.stop())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, imp.report)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/dmitriy-zverev/chirpy/internal/auth"
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// authenticate returns the ID of the user owning the request's bearer JWT
+func (cfg *ApiConfig) authenticate(req *http.Request) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	return auth.ValidateJWT(token, string(cfg.JWTSecret))
+}
+
+// optionalAuthenticate is authenticate for endpoints that are also open to
+// anonymous callers. It returns uuid.Nil when no Authorization header is set.
+func (cfg *ApiConfig) optionalAuthenticate(req *http.Request) (uuid.UUID, error) {
+	if req.Header.Get("Authorization") == "" {
+		return uuid.Nil, nil
+	}
+
+	return cfg.authenticate(req)
+}
+
+// authenticateAdmin authenticates the caller and makes sure they are an
+// admin, writing the error response when they aren't
+func (cfg *ApiConfig) authenticateAdmin(w http.ResponseWriter, req *http.Request) (uuid.UUID, bool) {
+	return cfg.authenticateStaff(w, req, func(user database.User) bool {
+		return user.IsAdmin
+	})
+}
+
+// authenticateModerator is authenticateAdmin for moderators, admins
+// moderate too
+func (cfg *ApiConfig) authenticateModerator(w http.ResponseWriter, req *http.Request) (uuid.UUID, bool) {
+	return cfg.authenticateStaff(w, req, func(user database.User) bool {
+		return user.IsAdmin || user.IsModerator
+	})
+}
+
+func (cfg *ApiConfig) authenticateStaff(w http.ResponseWriter, req *http.Request, allowed func(database.User) bool) (uuid.UUID, bool) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+
+	user, err := cfg.DbQueries.GetUser(context.Background(), userID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+
+	if !allowed(user) {
+		w.WriteHeader(http.StatusForbidden)
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// isBlocked reports whether either user blocks the other. Anonymous
+// callers (uuid.Nil) are never blocked.
+func (cfg *ApiConfig) isBlocked(ctx context.Context, userID, otherUserID uuid.UUID) (bool, error) {
+	if userID == uuid.Nil || otherUserID == uuid.Nil {
+		return false, nil
+	}
+
+	return cfg.DbQueries.IsBlocked(ctx, database.IsBlockedParams{
+		UserID:      userID,
+		OtherUserID: otherUserID,
+	})
+}
+
+// relationTarget authenticates the caller and resolves the {userID} path
+// value shared by the block and mute endpoints. It writes the error
+// response itself and returns ok == false when the request must stop.
+func (cfg *ApiConfig) relationTarget(w http.ResponseWriter, req *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	targetID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	if userID == targetID {
+		respondWithError(w, http.StatusBadRequest, "You cannot do this to yourself")
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	if _, err := cfg.DbQueries.GetUser(context.Background(), targetID); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	return userID, targetID, true
+}
+
+func (cfg *ApiConfig) BlockHandler(w http.ResponseWriter, req *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, req)
+	if !ok {
+		return
+	}
+
+	if err := cfg.DbQueries.BlockUser(context.Background(), database.BlockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// A block severs any follow relationship in both directions
+	if err := cfg.DbQueries.RemoveFollowsBetween(context.Background(), database.RemoveFollowsBetweenParams{
+		UserID:      userID,
+		OtherUserID: targetID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := cfg.Fanout.Unfollow(context.Background(), userID, targetID); err != nil {
+		log.Printf("%v\n", err)
+	}
+	if err := cfg.Fanout.Unfollow(context.Background(), targetID, userID); err != nil {
+		log.Printf("%v\n", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) UnblockHandler(w http.ResponseWriter, req *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, req)
+	if !ok {
+		return
+	}
+
+	if err := cfg.DbQueries.UnblockUser(context.Background(), database.UnblockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) MuteHandler(w http.ResponseWriter, req *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, req)
+	if !ok {
+		return
+	}
+
+	if err := cfg.DbQueries.MuteUser(context.Background(), database.MuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) UnmuteHandler(w http.ResponseWriter, req *http.Request) {
+	userID, targetID, ok := cfg.relationTarget(w, req)
+	if !ok {
+		return
+	}
+
+	if err := cfg.DbQueries.UnmuteUser(context.Background(), database.UnmuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
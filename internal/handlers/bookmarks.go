@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type bookmarkFolderJson struct {
+	Id        string `json:"id"`
+	CreatedAt string `json:"created_at"`
+	Name      string `json:"name"`
+}
+
+func newBookmarkFolderJson(folder database.BookmarkFolder) bookmarkFolderJson {
+	return bookmarkFolderJson{
+		Id:        folder.ID.String(),
+		CreatedAt: folder.CreatedAt.String(),
+		Name:      folder.Name,
+	}
+}
+
+func (cfg *ApiConfig) BookmarkPutHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		FolderID *uuid.UUID `json:"folder_id"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	chirp, err := cfg.DbQueries.GetVisibleChirp(
+		context.Background(),
+		database.GetVisibleChirpParams{
+			ID:       chirpID,
+			ViewerID: userID,
+		},
+	)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	blocked, err := cfg.isBlocked(context.Background(), userID, chirp.UserID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	folderID := uuid.NullUUID{}
+	if params.FolderID != nil {
+		isRed, err := cfg.isChirpyRed(context.Background(), userID)
+		if err != nil {
+			log.Printf("%v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !isRed {
+			respondWithError(w, http.StatusForbidden, "Bookmark folders require Chirpy Red")
+			return
+		}
+
+		folder, err := cfg.DbQueries.GetBookmarkFolder(context.Background(), *params.FolderID)
+		if err != nil || folder.UserID != userID {
+			respondWithError(w, http.StatusNotFound, "Folder not found")
+			return
+		}
+		folderID = uuid.NullUUID{UUID: folder.ID, Valid: true}
+	}
+
+	if err := cfg.DbQueries.UpsertBookmark(context.Background(), database.UpsertBookmarkParams{
+		UserID:   userID,
+		ChirpID:  chirp.ID,
+		FolderID: folderID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) BookmarkDeleteHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if err := cfg.DbQueries.DeleteBookmark(context.Background(), database.DeleteBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) BookmarksGetHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	before, limit, err := parsePage(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	folderID := uuid.NullUUID{}
+	if folderValue := req.URL.Query().Get("folder_id"); folderValue != "" {
+		parsedFolderID, err := uuid.Parse(folderValue)
+		if err != nil {
+			log.Printf("%v\n", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid folder ID")
+			return
+		}
+		folderID = uuid.NullUUID{UUID: parsedFolderID, Valid: true}
+	}
+
+	rows, err := cfg.DbQueries.GetBookmarks(context.Background(), database.GetBookmarksParams{
+		UserID:     userID,
+		Before:     before,
+		FolderID:   folderID,
+		MaxResults: limit,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	type bookmarkJson struct {
+		ChirpID   string     `json:"chirp_id"`
+		FolderID  *string    `json:"folder_id"`
+		CreatedAt string     `json:"created_at"`
+		Deleted   bool       `json:"deleted"`
+		Chirp     *chirpJson `json:"chirp"`
+		Cursor    string     `json:"cursor"`
+	}
+
+	bookmarks := []bookmarkJson{}
+	liveChirps := []database.Chirp{}
+	for _, row := range rows {
+		bookmark := bookmarkJson{
+			ChirpID:   row.ChirpID.String(),
+			CreatedAt: row.CreatedAt.String(),
+			Cursor:    row.CreatedAt.Format(time.RFC3339Nano),
+		}
+		if row.FolderID.Valid {
+			folderID := row.FolderID.UUID.String()
+			bookmark.FolderID = &folderID
+		}
+
+		// The chirp is gone, keep the bookmark as a tombstone. Chirps the
+		// user can no longer see aren't returned at all.
+		if row.ChirpDeletedAt.Valid || !row.ChirpUserID.Valid {
+			bookmark.Deleted = true
+			bookmarks = append(bookmarks, bookmark)
+			continue
+		}
+
+		liveChirps = append(liveChirps, database.Chirp{
+			ID:             row.ChirpID,
+			CreatedAt:      row.ChirpCreatedAt.Time,
+			UpdatedAt:      row.ChirpUpdatedAt.Time,
+			Body:           row.ChirpBody.String,
+			UserID:         row.ChirpUserID.UUID,
+			ContentWarning: row.ChirpContentWarning.String,
+			Sensitive:      row.ChirpSensitive.Bool,
+			Visibility:     row.ChirpVisibility.String,
+		})
+		bookmarks = append(bookmarks, bookmark)
+	}
+
+	chirpsJsons, err := cfg.chirpJsons(context.Background(), userID, liveChirps)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Live chirps were collected in bookmark order, skipping tombstones
+	next := 0
+	for i := range bookmarks {
+		if !bookmarks[i].Deleted {
+			bookmarks[i].Chirp = &chirpsJsons[next]
+			next++
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, bookmarks)
+}
+
+func (cfg *ApiConfig) BookmarkFoldersPostHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	isRed, err := cfg.isChirpyRed(context.Background(), userID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !isRed {
+		respondWithError(w, http.StatusForbidden, "Bookmark folders require Chirpy Red")
+		return
+	}
+
+	if params.Name == "" || len([]rune(params.Name)) > MAX_BOOKMARK_FOLDER_NAME_LENGTH {
+		respondWithError(
+			w,
+			http.StatusBadRequest,
+			fmt.Sprintf("Folder name must be 1 to %d characters", MAX_BOOKMARK_FOLDER_NAME_LENGTH),
+		)
+		return
+	}
+
+	folder, err := cfg.DbQueries.CreateBookmarkFolder(context.Background(), database.CreateBookmarkFolderParams{
+		UserID: userID,
+		Name:   params.Name,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newBookmarkFolderJson(folder))
+}
+
+func (cfg *ApiConfig) BookmarkFoldersGetHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	folders, err := cfg.DbQueries.GetBookmarkFolders(context.Background(), userID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	foldersJsons := []bookmarkFolderJson{}
+	for _, folder := range folders {
+		foldersJsons = append(foldersJsons, newBookmarkFolderJson(folder))
+	}
+
+	respondWithJSON(w, http.StatusOK, foldersJsons)
+}
+
+// BookmarkFolderDeleteHandler deletes a folder. Its bookmarks are kept and
+// move back out of any folder.
+func (cfg *ApiConfig) BookmarkFolderDeleteHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	folderID, err := uuid.Parse(req.PathValue("folderID"))
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if err := cfg.DbQueries.DeleteBookmarkFolder(context.Background(), database.DeleteBookmarkFolderParams{
+		ID:     folderID,
+		UserID: userID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
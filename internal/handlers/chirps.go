@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/chirptext"
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/dmitriy-zverev/chirpy/internal/moderation"
+	"github.com/google/uuid"
+)
+
+// chirpValidationError is a chirp body rejected for a reason the author
+// should be told about. Chirps that are too long also carry their
+// computed length and the limit that applied.
+type chirpValidationError struct {
+	Message string
+	Length  int
+	Limit   int
+}
+
+func (e *chirpValidationError) Error() string {
+	return e.Message
+}
+
+func respondWithChirpValidationError(w http.ResponseWriter, code int, err *chirpValidationError) {
+	type response struct {
+		Error  string `json:"error"`
+		Length *int   `json:"length,omitempty"`
+		Limit  *int   `json:"limit,omitempty"`
+	}
+
+	resp := response{Error: err.Message}
+	if err.Limit > 0 {
+		resp.Length = &err.Length
+		resp.Limit = &err.Limit
+	}
+	respondWithJSON(w, code, resp)
+}
+
+// chirpHeldError reports a chirp held back for moderator review. It is not
+// a failure: the chirp was stored in held_chirps, so callers must commit.
+type chirpHeldError struct {
+	ID uuid.UUID
+}
+
+func (e *chirpHeldError) Error() string {
+	return fmt.Sprintf("chirp held for review as %s", e.ID)
+}
+
+// validateChirp checks a chirp body against userID's length limit, which
+// is higher for Chirpy Red. Length is counted in user-perceived
+// characters with URLs at a fixed weight, see chirptext.Length.
+func (cfg *ApiConfig) validateChirp(ctx context.Context, userID uuid.UUID, body string) error {
+	length := chirptext.Length(body, CHIRP_URL_WEIGHT)
+	if length <= cfg.ChirpMaxLength {
+		return nil
+	}
+
+	isRed, err := cfg.isChirpyRed(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	limit := cfg.ChirpMaxLength
+	if isRed {
+		limit = cfg.ChirpMaxLengthRed
+	}
+	if length > limit {
+		return &chirpValidationError{
+			Message: fmt.Sprintf("Chirp is too long: %d characters, the limit is %d", length, limit),
+			Length:  length,
+			Limit:   limit,
+		}
+	}
+
+	return nil
+}
+
+// newChirp is what an author submits to publish a chirp. ContentWarning
+// is spoiler text shown in place of the body, Sensitive flags the chirp's
+// media; either one collapses the chirp for viewers who haven't opted in.
+// Visibility defaults to public, mentioned users can always see the chirp.
+// CreatedAt is only set by imports, which keep the original timestamp.
+type newChirp struct {
+	Body           string      `json:"body"`
+	ContentWarning string      `json:"content_warning"`
+	Sensitive      bool        `json:"sensitive"`
+	Visibility     string      `json:"visibility"`
+	Mentions       []uuid.UUID `json:"mentions"`
+	MediaIDs       []uuid.UUID `json:"media_ids"`
+	Poll           *newPoll    `json:"poll"`
+	CreatedAt      time.Time   `json:"-"`
+}
+
+// heldChirpPayload is how a held chirp is stored. It adds the timestamp
+// newChirp keeps out of its JSON, so an imported chirp that was held is
+// still published with its original one.
+type heldChirpPayload struct {
+	newChirp
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+func newHeldChirpPayload(input newChirp) heldChirpPayload {
+	payload := heldChirpPayload{newChirp: input}
+	if !input.CreatedAt.IsZero() {
+		payload.CreatedAt = &input.CreatedAt
+	}
+	return payload
+}
+
+// chirp returns the held chirp as it was submitted
+func (payload heldChirpPayload) chirp() newChirp {
+	input := payload.newChirp
+	if payload.CreatedAt != nil {
+		input.CreatedAt = *payload.CreatedAt
+	}
+	return input
+}
+
+func (cfg *ApiConfig) validateNewChirp(ctx context.Context, userID uuid.UUID, input newChirp) error {
+	if err := cfg.validateChirp(ctx, userID, input.Body); err != nil {
+		return err
+	}
+
+	if chirptext.Graphemes(input.ContentWarning) > MAX_CONTENT_WARNING_LENGTH {
+		return &chirpValidationError{
+			Message: fmt.Sprintf("A content warning can be at most %d characters", MAX_CONTENT_WARNING_LENGTH),
+		}
+	}
+
+	if err := validateVisibility(input.Visibility, input.Mentions); err != nil {
+		return err
+	}
+
+	if len(input.MediaIDs) > MAX_CHIRP_MEDIA {
+		return &chirpValidationError{
+			Message: fmt.Sprintf("A chirp can have at most %d media attachments", MAX_CHIRP_MEDIA),
+		}
+	}
+
+	if input.Poll != nil {
+		if _, err := input.Poll.validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createChirp validates input, runs it through the moderation rules and
+// stores it as a chirp of userID. Every path that publishes chirps goes
+// through here; q may be bound to a transaction, which callers must roll
+// back on error since attaching media can fail after the chirp row exists.
+// A *chirpHeldError is returned when a rule holds the chirp for review.
+func (cfg *ApiConfig) createChirp(ctx context.Context, q *database.Queries, userID uuid.UUID, input newChirp) (database.Chirp, error) {
+	if err := cfg.validateNewChirp(ctx, userID, input); err != nil {
+		return database.Chirp{}, err
+	}
+
+	moderated, action := cfg.moderateChirp(input)
+	switch action {
+	case moderation.ActionReject:
+		return database.Chirp{}, &chirpValidationError{Message: "Chirp violates the content rules"}
+	case moderation.ActionHold:
+		payload, err := json.Marshal(newHeldChirpPayload(input))
+		if err != nil {
+			return database.Chirp{}, err
+		}
+		held, err := q.CreateHeldChirp(ctx, database.CreateHeldChirpParams{
+			UserID:  userID,
+			Body:    input.Body,
+			Payload: payload,
+		})
+		if err != nil {
+			return database.Chirp{}, err
+		}
+		return database.Chirp{}, &chirpHeldError{ID: held.ID}
+	}
+
+	return cfg.insertChirp(ctx, q, userID, moderated)
+}
+
+// moderateChirp runs the chirp's body and content warning through the
+// moderation rules. It returns input with both texts masked and the most
+// severe action either of them calls for.
+func (cfg *ApiConfig) moderateChirp(input newChirp) (newChirp, moderation.Action) {
+	body := cfg.Moderation.Check(input.Body)
+	warning := cfg.Moderation.Check(input.ContentWarning)
+
+	input.Body = body.Body
+	input.ContentWarning = warning.Body
+	return input, moderation.MostSevere(body.Action, warning.Action)
+}
+
+// insertChirp stores an already moderated chirp with its media and poll,
+// and notifies the users it mentions
+func (cfg *ApiConfig) insertChirp(ctx context.Context, q *database.Queries, userID uuid.UUID, input newChirp) (database.Chirp, error) {
+	visibility := input.Visibility
+	if visibility == "" {
+		visibility = CHIRP_VISIBILITY_PUBLIC
+	}
+
+	chirp, err := q.CreateChirp(ctx, database.CreateChirpParams{
+		CreatedAt:      sql.NullTime{Time: input.CreatedAt, Valid: !input.CreatedAt.IsZero()},
+		Body:           input.Body,
+		UserID:         userID,
+		ContentWarning: input.ContentWarning,
+		Sensitive:      input.Sensitive,
+		Visibility:     visibility,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	// Mentions of users either side of a block are dropped, as a mention
+	// would let them see the chirp
+	if len(input.Mentions) > 0 {
+		if err := q.AddChirpMentions(ctx, database.AddChirpMentionsParams{
+			ChirpID:  chirp.ID,
+			UserIds:  input.Mentions,
+			AuthorID: userID,
+		}); err != nil {
+			return database.Chirp{}, err
+		}
+
+		// Imported chirps are old news
+		if input.CreatedAt.IsZero() {
+			if err := cfg.notifyMentions(ctx, q, chirp); err != nil {
+				return database.Chirp{}, err
+			}
+		}
+	}
+
+	for i, mediaID := range input.MediaIDs {
+		attached, err := q.AttachMedia(ctx, database.AttachMediaParams{
+			ID:       mediaID,
+			UserID:   userID,
+			ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Position: int32(i),
+		})
+		if err != nil {
+			return database.Chirp{}, err
+		}
+		if attached == 0 {
+			return database.Chirp{}, &chirpValidationError{
+				Message: fmt.Sprintf("Media %s does not exist or is already attached", mediaID),
+			}
+		}
+	}
+
+	if input.Poll != nil {
+		if err := createPoll(ctx, q, chirp.ID, *input.Poll); err != nil {
+			return database.Chirp{}, err
+		}
+	}
+
+	if err := enqueueWebhook(ctx, q, WEBHOOK_EVENT_CHIRP_CREATED, userID, newWebhookChirpJson(chirp)); err != nil {
+		return database.Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+type chirpJson struct {
+	Id             string      `json:"id"`
+	CreatedAt      string      `json:"created_at"`
+	UpdatedAt      string      `json:"updated_at"`
+	Body           string      `json:"body"`
+	UserID         string      `json:"user_id"`
+	ContentWarning string      `json:"content_warning"`
+	Sensitive      bool        `json:"sensitive"`
+	Collapsed      bool        `json:"collapsed"`
+	Visibility     string      `json:"visibility"`
+	Mentions       []string    `json:"mentions"`
+	Pinned         *bool       `json:"pinned,omitempty"`
+	Media          []mediaJson `json:"media"`
+	Poll           *pollJson   `json:"poll,omitempty"`
+}
+
+func newChirpJson(chirp database.Chirp) chirpJson {
+	return chirpJson{
+		Id:             chirp.ID.String(),
+		CreatedAt:      chirp.CreatedAt.String(),
+		UpdatedAt:      chirp.UpdatedAt.String(),
+		Body:           chirp.Body,
+		UserID:         chirp.UserID.String(),
+		ContentWarning: chirp.ContentWarning,
+		Sensitive:      chirp.Sensitive,
+		Visibility:     chirp.Visibility,
+		Mentions:       []string{},
+		Media:          []mediaJson{},
+	}
+}
+
+func newChirpJsons(chirps []database.Chirp) []chirpJson {
+	chirpsJsons := []chirpJson{}
+	for _, chirp := range chirps {
+		chirpsJsons = append(chirpsJsons, newChirpJson(chirp))
+	}
+	return chirpsJsons
+}
+
+// chirpJsons renders a listing of chirps as seen by viewerID. Chirps
+// behind a content warning are collapsed unless the viewer chose to expand
+// them, see collapseSensitive.
+func (cfg *ApiConfig) chirpJsons(ctx context.Context, viewerID uuid.UUID, chirps []database.Chirp) ([]chirpJson, error) {
+	chirpsJsons, err := cfg.renderChirps(ctx, viewerID, chirps)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.collapseSensitive(ctx, viewerID, chirps, chirpsJsons); err != nil {
+		return nil, err
+	}
+
+	return chirpsJsons, nil
+}
+
+// chirpJson renders a single chirp the viewer asked for, which is never
+// collapsed: opening a chirp is how a viewer expands it
+func (cfg *ApiConfig) chirpJson(ctx context.Context, viewerID uuid.UUID, chirp database.Chirp) (chirpJson, error) {
+	chirpsJsons, err := cfg.renderChirps(ctx, viewerID, []database.Chirp{chirp})
+	if err != nil {
+		return chirpJson{}, err
+	}
+
+	return chirpsJsons[0], nil
+}
+
+// renderChirps renders chirps along with their mentions, media and polls,
+// which are loaded for all of them in a handful of queries
+func (cfg *ApiConfig) renderChirps(ctx context.Context, viewerID uuid.UUID, chirps []database.Chirp) ([]chirpJson, error) {
+	chirpsJsons := newChirpJsons(chirps)
+	if len(chirps) == 0 {
+		return chirpsJsons, nil
+	}
+
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	mentionsByChirp, err := cfg.chirpMentions(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaByChirp, err := cfg.chirpMedia(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	pollsByChirp, err := cfg.chirpPolls(ctx, viewerID, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, chirp := range chirps {
+		if mentions, ok := mentionsByChirp[chirp.ID]; ok {
+			chirpsJsons[i].Mentions = mentions
+		}
+		if chirpMedia, ok := mediaByChirp[chirp.ID]; ok {
+			chirpsJsons[i].Media = chirpMedia
+		}
+		chirpsJsons[i].Poll = pollsByChirp[chirp.ID]
+	}
+
+	return chirpsJsons, nil
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// isChirpyRed reports whether the user is on the Chirpy Red plan, which
+// lifts the limits free accounts are subject to. It is read off the
+// subscription, which counts until its period ends even before the
+// expirer gets to it.
+func (cfg *ApiConfig) isChirpyRed(ctx context.Context, userID uuid.UUID) (bool, error) {
+	return cfg.DbQueries.IsChirpyRed(ctx, userID)
+}
+
+// activateSubscription starts or renews the user's subscription. Without
+// a period end from Polka, an upgrade runs for one period from now and a
+// renewal adds a period to whatever is left of the current one.
+func activateSubscription(ctx context.Context, q *database.Queries, event string, userID uuid.UUID, plan string, periodEnd *time.Time) (database.Subscription, error) {
+	now := time.Now().UTC()
+	end := now.Add(CHIRPY_RED_PERIOD)
+
+	current, err := q.GetSubscription(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return database.Subscription{}, err
+	}
+	if err == nil {
+		if plan == "" {
+			plan = current.Plan
+		}
+		if event == POLKA_USER_RENEWED && current.CurrentPeriodEnd.After(now) {
+			end = current.CurrentPeriodEnd.Add(CHIRPY_RED_PERIOD)
+		}
+	}
+	if plan == "" {
+		plan = CHIRPY_RED_DEFAULT_PLAN
+	}
+	if periodEnd != nil {
+		end = periodEnd.UTC()
+	}
+
+	return q.ActivateSubscription(ctx, database.ActivateSubscriptionParams{
+		UserID:           userID,
+		Plan:             plan,
+		CurrentPeriodEnd: end,
+	})
+}
+
+// RunSubscriptionExpirer marks lapsed subscriptions expired every interval
+// until ctx is done. Canceled and past due subscriptions keep Chirpy Red
+// until the end of the period they were paid for.
+func (cfg *ApiConfig) RunSubscriptionExpirer(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := cfg.DbQueries.ExpireSubscriptions(ctx)
+			if err != nil {
+				log.Printf("subscription expirer: %v\n", err)
+				continue
+			}
+			if expired > 0 {
+				log.Printf("subscription expirer: expired %d subscriptions\n", expired)
+			}
+		}
+	}
+}
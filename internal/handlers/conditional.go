@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// chirpsETag is a strong ETag for chirps as rendered for viewerID. It is
+// derived from each chirp's ID and updated_at, which every change to a
+// chirp's representation bumps except poll votes, the version of its
+// poll, plus the rest of what shapes the response: the viewer, their
+// expand_sensitive preference and variant, which listings use for their
+// query parameters and pins.
+func chirpsETag(viewerID uuid.UUID, expandSensitive bool, variant string, chirps []database.Chirp, polls map[uuid.UUID]pollVersion) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%t\n%s\n", viewerID, expandSensitive, variant)
+	for _, chirp := range chirps {
+		fmt.Fprintf(hash, "%s %d", chirp.ID, chirp.UpdatedAt.UnixNano())
+		if poll, ok := polls[chirp.ID]; ok {
+			fmt.Fprintf(hash, " %d %d %t", poll.votes, poll.lastVoteAt.UnixNano(), poll.closed)
+		}
+		fmt.Fprintln(hash)
+	}
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// chirpLastModified is when chirp or the results of its poll last changed
+func chirpLastModified(chirp database.Chirp, polls map[uuid.UUID]pollVersion) time.Time {
+	if poll, ok := polls[chirp.ID]; ok && poll.lastVoteAt.After(chirp.UpdatedAt) {
+		return poll.lastVoteAt
+	}
+	return chirp.UpdatedAt
+}
+
+// etagMatches reports whether etag is in an If-Match or If-None-Match
+// header. If-None-Match compares weakly, ignoring W/ prefixes; If-Match
+// compares strongly, so weak tags never match.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when there is
+// none, against the current representation
+func notModified(req *http.Request, etag string, lastModified time.Time) bool {
+	if header := req.Header.Get("If-None-Match"); header != "" {
+		return etagMatches(header, etag, true)
+	}
+
+	if header := req.Header.Get("If-Modified-Since"); header != "" {
+		since, err := http.ParseTime(header)
+		if err != nil {
+			return false
+		}
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+
+	return false
+}
+
+// etagNotModified evaluates If-None-Match alone, for responses whose
+// Last-Modified doesn't capture everything they depend on
+func etagNotModified(req *http.Request, etag string) bool {
+	header := req.Header.Get("If-None-Match")
+	return header != "" && etagMatches(header, etag, true)
+}
+
+// preconditionFailed reports whether an If-Match header rules out etag,
+// meaning the client's copy is out of date and its write must not win
+func preconditionFailed(req *http.Request, etag string) bool {
+	header := req.Header.Get("If-Match")
+	return header != "" && !etagMatches(header, etag, false)
+}
+
+// setValidators sets the headers clients revalidate a response with.
+// Responses depend on who is asking, so they are private to the caller.
+func setValidators(w http.ResponseWriter, etag string, lastModified time.Time) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Vary", "Authorization")
+}
+
+func writeNotModified(w http.ResponseWriter, etag string, lastModified time.Time) {
+	setValidators(w, etag, lastModified)
+	w.WriteHeader(http.StatusNotModified)
+}
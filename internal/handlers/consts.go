@@ -1,5 +1,128 @@
 package handlers
 
+import "time"
+
 const (
-	POLKA_WEBHOOK_EVENT = "user.upgraded"
+	POLKA_USER_UPGRADED   = "user.upgraded"
+	POLKA_USER_RENEWED    = "user.renewed"
+	POLKA_USER_DOWNGRADED = "user.downgraded"
+	POLKA_PAYMENT_FAILED  = "payment.failed"
+
+	CHIRPY_RED_DEFAULT_PLAN      = "monthly"
+	CHIRPY_RED_PERIOD            = 30 * 24 * time.Hour
+	MAX_SUBSCRIPTION_PLAN_LENGTH = 50
+
+	POLKA_SIGNATURE_HEADER = "X-Polka-Signature"
+	POLKA_TIMESTAMP_HEADER = "X-Polka-Timestamp"
+	MAX_POLKA_BODY_BYTES   = 64 << 10
+
+	POLKA_EVENT_PROCESSING = "processing"
+	POLKA_EVENT_PROCESSED  = "processed"
+	POLKA_EVENT_IGNORED    = "ignored"
+	POLKA_EVENT_FAILED     = "failed"
+
+	MAX_POLKA_EVENT_ID_LENGTH    = 255
+	MAX_POLKA_EVENT_ERROR_LENGTH = 500
+	POLKA_EVENT_STALE_AFTER      = 5 * time.Minute
+
+	NOTIFICATION_MENTION = "mention"
+	NOTIFICATION_FOLLOW  = "follow"
+
+	NOTIFICATION_POLL_CLOSED = "poll_closed"
+
+	MAX_CONVERSATION_PARTICIPANTS = 10
+	FREE_DAILY_CONVERSATIONS      = 20
+	MAX_MESSAGE_LENGTH            = 2000
+
+	MAX_LIST_NAME_LENGTH = 50
+	MAX_LIST_MEMBERS     = 500
+
+	MAX_BOOKMARK_FOLDER_NAME_LENGTH = 50
+
+	FREE_PINNED_CHIRPS = 1
+	RED_PINNED_CHIRPS  = 5
+
+	SCHEDULED_STATUS_DRAFT     = "draft"
+	SCHEDULED_STATUS_SCHEDULED = "scheduled"
+	SCHEDULED_STATUS_PUBLISHED = "published"
+	SCHEDULED_STATUS_FAILED    = "failed"
+	MAX_SCHEDULE_ATTEMPTS      = 5
+
+	DEFAULT_PAGE_SIZE = 20
+	MAX_PAGE_SIZE     = 100
+
+	MAX_CHIRP_MEDIA          = 4
+	FREE_MEDIA_MAX_BYTES     = 5 << 20
+	RED_MEDIA_MAX_BYTES      = 15 << 20
+	MULTIPART_OVERHEAD_BYTES = 64 << 10
+
+	MIN_POLL_OPTIONS       = 2
+	MAX_POLL_OPTIONS       = 4
+	MAX_POLL_OPTION_LENGTH = 25
+	MIN_POLL_DURATION      = 5 * time.Minute
+	MAX_POLL_DURATION      = 7 * 24 * time.Hour
+
+	REPORT_STATUS_DISMISSED = "dismissed"
+	REPORT_STATUS_ACTIONED  = "actioned"
+	MAX_REPORT_DETAILS      = 500
+
+	REPORT_ACTION_DISMISS = "dismiss"
+	REPORT_ACTION_REMOVE  = "remove"
+	REPORT_ACTION_SUSPEND = "suspend"
+
+	CHIRP_TRASH_RETENTION = 30 * 24 * time.Hour
+
+	CHIRP_URL_WEIGHT = 23
+
+	MAX_CONTENT_WARNING_LENGTH = 100
+
+	CHIRP_VISIBILITY_PUBLIC    = "public"
+	CHIRP_VISIBILITY_FOLLOWERS = "followers"
+	CHIRP_VISIBILITY_MENTIONED = "mentioned"
+	MAX_CHIRP_MENTIONS         = 10
+
+	IDEMPOTENCY_KEY_TTL        = 24 * time.Hour
+	IDEMPOTENCY_CLAIM_LEASE    = time.Minute
+	MAX_IDEMPOTENCY_KEY_LENGTH = 255
+
+	EXPORT_PAGE_SIZE      = 500
+	IMPORT_BATCH_SIZE     = 100
+	MAX_IMPORT_BYTES      = 32 << 20
+	MAX_IMPORT_LINE_BYTES = 64 << 10
+
+	STREAM_HEARTBEAT_INTERVAL = 15 * time.Second
+	MAX_STREAM_HASHTAGS       = 10
+
+	WS_MAX_MESSAGE_SIZE = 4 << 10
+	WS_IDLE_TIMEOUT     = 60 * time.Second
+	WS_PING_INTERVAL    = 25 * time.Second
+	WS_SEND_QUEUE_SIZE  = 64
+	MAX_WS_CHANNELS     = 50
+
+	WS_CHANNEL_TIMELINE      = "timeline"
+	WS_CHANNEL_NOTIFICATIONS = "notifications"
+	WS_CHANNEL_THREAD_PREFIX = "thread:"
+
+	WS_MESSAGE_AUTH         = "auth"
+	WS_MESSAGE_READY        = "ready"
+	WS_MESSAGE_SUBSCRIBE    = "subscribe"
+	WS_MESSAGE_SUBSCRIBED   = "subscribed"
+	WS_MESSAGE_UNSUBSCRIBE  = "unsubscribe"
+	WS_MESSAGE_UNSUBSCRIBED = "unsubscribed"
+	WS_MESSAGE_ERROR        = "error"
+
+	WEBHOOK_EVENT_CHIRP_CREATED = "chirp.created"
+	WEBHOOK_EVENT_CHIRP_DELETED = "chirp.deleted"
+	WEBHOOK_EVENT_USER_UPGRADED = "user.upgraded"
+
+	WEBHOOK_DELIVERY_PENDING   = "pending"
+	WEBHOOK_DELIVERY_DELIVERED = "delivered"
+	WEBHOOK_DELIVERY_DEAD      = "dead"
+
+	MAX_WEBHOOK_SUBSCRIPTIONS  = 10
+	MAX_WEBHOOK_URL_LENGTH     = 2048
+	MAX_WEBHOOK_ATTEMPTS       = 12
+	MAX_WEBHOOK_ERROR_LENGTH   = 500
+	WEBHOOK_TIMEOUT            = 10 * time.Second
+	WEBHOOK_DELIVERY_RETENTION = 30 * 24 * time.Hour
 )
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// collapseSensitive hides the body, media and poll of chirps that carry a
+// content warning or are flagged sensitive, leaving only the warning for
+// clients to show. Viewers always see their own chirps, and those who set
+// expand_sensitive see everything expanded.
+func (cfg *ApiConfig) collapseSensitive(ctx context.Context, viewerID uuid.UUID, chirps []database.Chirp, chirpsJsons []chirpJson) error {
+	collapse := make([]bool, len(chirps))
+	anyCollapsed := false
+	for i, chirp := range chirps {
+		collapse[i] = (chirp.ContentWarning != "" || chirp.Sensitive) && chirp.UserID != viewerID
+		anyCollapsed = anyCollapsed || collapse[i]
+	}
+	if !anyCollapsed {
+		return nil
+	}
+
+	expand, err := cfg.expandsSensitive(ctx, viewerID)
+	if err != nil {
+		return err
+	}
+	if expand {
+		return nil
+	}
+
+	for i := range chirpsJsons {
+		if !collapse[i] {
+			continue
+		}
+		chirpsJsons[i].Body = ""
+		chirpsJsons[i].Media = []mediaJson{}
+		chirpsJsons[i].Poll = nil
+		chirpsJsons[i].Collapsed = true
+	}
+
+	return nil
+}
+
+// expandsSensitive reports whether viewerID wants chirps behind content
+// warnings expanded, anonymous viewers don't
+func (cfg *ApiConfig) expandsSensitive(ctx context.Context, viewerID uuid.UUID) (bool, error) {
+	if viewerID == uuid.Nil {
+		return false, nil
+	}
+
+	viewer, err := cfg.DbQueries.GetUser(ctx, viewerID)
+	if err != nil {
+		return false, err
+	}
+	return viewer.ExpandSensitive, nil
+}
+
+type preferencesJson struct {
+	ExpandSensitive bool `json:"expand_sensitive"`
+}
+
+func (cfg *ApiConfig) PreferencesGetHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	user, err := cfg.DbQueries.GetUser(context.Background(), userID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, preferencesJson{
+		ExpandSensitive: user.ExpandSensitive,
+	})
+}
+
+func (cfg *ApiConfig) PreferencesPutHandler(w http.ResponseWriter, req *http.Request) {
+	params := preferencesJson{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if err := cfg.DbQueries.SetExpandSensitive(
+		context.Background(),
+		database.SetExpandSensitiveParams{
+			ID:              userID,
+			ExpandSensitive: params.ExpandSensitive,
+		},
+	); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, params)
+}
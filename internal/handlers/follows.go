@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func (cfg *ApiConfig) FollowHandler(w http.ResponseWriter, req *http.Request) {
+	followerID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	followeeID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if followerID == followeeID {
+		respondWithError(w, http.StatusBadRequest, "You cannot follow yourself")
+		return
+	}
+
+	if _, err := cfg.DbQueries.GetUser(context.Background(), followeeID); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	blocked, err := cfg.isBlocked(context.Background(), followerID, followeeID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You cannot follow this user")
+		return
+	}
+
+	fanoutOnRead, err := cfg.DbQueries.FollowUser(context.Background(), database.FollowUserParams{
+		FollowerID:      followerID,
+		FolloweeID:      followeeID,
+		FanoutThreshold: cfg.Fanout.Threshold(),
+	})
+	// Following someone twice is a no-op
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := cfg.Fanout.Follow(context.Background(), followerID, followeeID, fanoutOnRead); err != nil {
+		log.Printf("%v\n", err)
+	}
+
+	cfg.notify(context.Background(), followeeID, followerID, NOTIFICATION_FOLLOW, uuid.Nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) UnfollowHandler(w http.ResponseWriter, req *http.Request) {
+	followerID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	followeeID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := cfg.DbQueries.UnfollowUser(context.Background(), database.UnfollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := cfg.Fanout.Unfollow(context.Background(), followerID, followeeID); err != nil {
+		log.Printf("%v\n", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
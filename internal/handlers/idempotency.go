@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+)
+
+// IdempotencyScope names who is making a request, so the same key sent by
+// two callers never collides. It returns false when the request can't be
+// attributed, which leaves it to the handler to reject.
+type IdempotencyScope func(req *http.Request) (string, bool)
+
+// UserIdempotencyScope scopes keys to the authenticated user
+func (cfg *ApiConfig) UserIdempotencyScope(req *http.Request) (string, bool) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		return "", false
+	}
+	return "user:" + userID.String(), true
+}
+
+// EmailIdempotencyScope scopes keys to a fingerprint of the email in the
+// body, for endpoints such as sign up that are used before there is a
+// user. Two people picking the same key never see each other's response.
+// The body is put back for the handler to read.
+func (cfg *ApiConfig) EmailIdempotencyScope(req *http.Request) (string, bool) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", false
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	params := struct {
+		Email string `json:"email"`
+	}{}
+	if err := json.Unmarshal(body, &params); err != nil {
+		return "", false
+	}
+	email := strings.ToLower(strings.TrimSpace(params.Email))
+	if email == "" {
+		return "", false
+	}
+
+	fingerprint := sha256.Sum256([]byte(email))
+	return "email:" + hex.EncodeToString(fingerprint[:]), true
+}
+
+// PolkaIdempotencyScope scopes keys to Polka, once its signature or API
+// key checks out. The body is put back for the handler to read.
+func (cfg *ApiConfig) PolkaIdempotencyScope(req *http.Request) (string, bool) {
+	body, err := io.ReadAll(io.LimitReader(req.Body, MAX_POLKA_BODY_BYTES))
+	if err != nil {
+		return "", false
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := cfg.authenticatePolka(req.Header, body); err != nil {
+		return "", false
+	}
+	return "polka", true
+}
+
+// idempotencyRecorder passes a response through while keeping a copy of it
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *idempotencyRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *idempotencyRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+// MiddlewareIdempotency makes POST requests carrying an Idempotency-Key
+// header safe to retry. The first request with a key runs as usual and
+// its response is stored for IDEMPOTENCY_KEY_TTL; a retry with the same
+// key and body gets that response replayed, one with a different body a
+// 422, and one that arrives while the first is still running a 409.
+// Server errors aren't stored, so a retry after one runs again. Claims are
+// leased for IDEMPOTENCY_CLAIM_LEASE, a retry after a replica died while
+// handling the first request runs it again once the lease is up. Should
+// the first request finish after all, its response isn't stored.
+func (cfg *ApiConfig) MiddlewareIdempotency(scope IdempotencyScope, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, req)
+			return
+		}
+		if len(key) > MAX_IDEMPOTENCY_KEY_LENGTH {
+			respondWithError(w, http.StatusBadRequest, "Idempotency-Key is too long")
+			return
+		}
+
+		who, ok := scope(req)
+		if !ok {
+			next.ServeHTTP(w, req)
+			return
+		}
+		who = req.Method + " " + req.URL.Path + " " + who
+
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			log.Printf("%v\n", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(hash[:])
+
+		// The lease identifies this claim when it is completed or released,
+		// so it's truncated to the precision Postgres stores
+		leaseUntil := time.Now().UTC().Add(IDEMPOTENCY_CLAIM_LEASE).Truncate(time.Microsecond)
+		claimed, err := cfg.DbQueries.ClaimIdempotencyKey(req.Context(), database.ClaimIdempotencyKeyParams{
+			Scope:         who,
+			Key:           key,
+			RequestHash:   requestHash,
+			LeaseUntil:    leaseUntil,
+			ExpiredBefore: time.Now().UTC().Add(-IDEMPOTENCY_KEY_TTL),
+		})
+		if err != nil {
+			log.Printf("%v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if claimed == 0 {
+			cfg.replayIdempotentResponse(req.Context(), w, who, key, requestHash)
+			return
+		}
+
+		recorder := &idempotencyRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, req)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		// A background context, the client may already have given up
+		ctx := context.Background()
+		if recorder.status >= http.StatusInternalServerError {
+			if err := cfg.DbQueries.ReleaseIdempotencyKey(ctx, database.ReleaseIdempotencyKeyParams{
+				Scope:      who,
+				Key:        key,
+				LeaseUntil: leaseUntil,
+			}); err != nil {
+				log.Printf("%v\n", err)
+			}
+			return
+		}
+
+		if err := cfg.DbQueries.CompleteIdempotencyKey(ctx, database.CompleteIdempotencyKeyParams{
+			Scope:        who,
+			Key:          key,
+			StatusCode:   sql.NullInt32{Int32: int32(recorder.status), Valid: true},
+			ContentType:  recorder.Header().Get("Content-Type"),
+			ResponseBody: recorder.body.Bytes(),
+			LeaseUntil:   leaseUntil,
+		}); err != nil {
+			log.Printf("%v\n", err)
+		}
+	})
+}
+
+func (cfg *ApiConfig) replayIdempotentResponse(ctx context.Context, w http.ResponseWriter, scope, key, requestHash string) {
+	stored, err := cfg.DbQueries.GetIdempotencyKey(ctx, database.GetIdempotencyKeyParams{
+		Scope: scope,
+		Key:   key,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// Released by a failed first attempt in the meantime
+		respondWithError(w, http.StatusConflict, "A request with this Idempotency-Key failed, retry it")
+		return
+	}
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if stored.RequestHash != requestHash {
+		respondWithError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+		return
+	}
+	if !stored.StatusCode.Valid {
+		respondWithError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+		return
+	}
+
+	if stored.ContentType != "" {
+		w.Header().Set("Content-Type", stored.ContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(int(stored.StatusCode.Int32))
+	w.Write(stored.ResponseBody)
+}
+
+// RunIdempotencyKeyPurger deletes stored responses older than
+// IDEMPOTENCY_KEY_TTL, checking every interval until ctx is done
+func (cfg *ApiConfig) RunIdempotencyKeyPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := cfg.DbQueries.PurgeIdempotencyKeys(ctx, time.Now().UTC().Add(-IDEMPOTENCY_KEY_TTL))
+			if err != nil {
+				log.Printf("idempotency key purger: %v\n", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("idempotency key purger: purged %d keys\n", purged)
+			}
+		}
+	}
+}
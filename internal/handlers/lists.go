@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type listJson struct {
+	Id        string `json:"id"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	OwnerID   string `json:"owner_id"`
+	Name      string `json:"name"`
+	Private   bool   `json:"private"`
+}
+
+func newListJson(list database.List) listJson {
+	return listJson{
+		Id:        list.ID.String(),
+		CreatedAt: list.CreatedAt.String(),
+		UpdatedAt: list.UpdatedAt.String(),
+		OwnerID:   list.OwnerID.String(),
+		Name:      list.Name,
+		Private:   list.IsPrivate,
+	}
+}
+
+type listParameters struct {
+	Name    string `json:"name"`
+	Private bool   `json:"private"`
+}
+
+func (params listParameters) validate() error {
+	if params.Name == "" || len([]rune(params.Name)) > MAX_LIST_NAME_LENGTH {
+		return fmt.Errorf("List name must be 1 to %d characters", MAX_LIST_NAME_LENGTH)
+	}
+	return nil
+}
+
+// listForRequest resolves the {listID} path value for the caller. Private
+// lists of other users answer 404, and so do lists the caller doesn't own
+// when ownerOnly is set. It writes the error response itself and returns
+// ok == false when the request must stop.
+func (cfg *ApiConfig) listForRequest(w http.ResponseWriter, req *http.Request, ownerOnly bool) (database.List, uuid.UUID, bool) {
+	var viewerID uuid.UUID
+	var err error
+	if ownerOnly {
+		viewerID, err = cfg.authenticate(req)
+	} else {
+		viewerID, err = cfg.optionalAuthenticate(req)
+	}
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return database.List{}, uuid.UUID{}, false
+	}
+
+	listID, err := uuid.Parse(req.PathValue("listID"))
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return database.List{}, uuid.UUID{}, false
+	}
+
+	list, err := cfg.DbQueries.GetList(context.Background(), listID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return database.List{}, uuid.UUID{}, false
+	}
+
+	if list.OwnerID != viewerID && (ownerOnly || list.IsPrivate) {
+		w.WriteHeader(http.StatusNotFound)
+		return database.List{}, uuid.UUID{}, false
+	}
+
+	return list, viewerID, true
+}
+
+func (cfg *ApiConfig) ListsPostHandler(w http.ResponseWriter, req *http.Request) {
+	params := listParameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if err := params.validate(); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	list, err := cfg.DbQueries.CreateList(context.Background(), database.CreateListParams{
+		OwnerID:   userID,
+		Name:      params.Name,
+		IsPrivate: params.Private,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newListJson(list))
+}
+
+func (cfg *ApiConfig) ListsGetHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	lists, err := cfg.DbQueries.GetListsByOwner(context.Background(), userID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	listsJsons := []listJson{}
+	for _, list := range lists {
+		listsJsons = append(listsJsons, newListJson(list))
+	}
+
+	respondWithJSON(w, http.StatusOK, listsJsons)
+}
+
+func (cfg *ApiConfig) ListGetHandler(w http.ResponseWriter, req *http.Request) {
+	list, _, ok := cfg.listForRequest(w, req, false)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newListJson(list))
+}
+
+func (cfg *ApiConfig) ListPutHandler(w http.ResponseWriter, req *http.Request) {
+	params := listParameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	list, _, ok := cfg.listForRequest(w, req, true)
+	if !ok {
+		return
+	}
+
+	if err := params.validate(); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	list, err := cfg.DbQueries.UpdateList(context.Background(), database.UpdateListParams{
+		ID:        list.ID,
+		Name:      params.Name,
+		IsPrivate: params.Private,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newListJson(list))
+}
+
+func (cfg *ApiConfig) ListDeleteHandler(w http.ResponseWriter, req *http.Request) {
+	list, _, ok := cfg.listForRequest(w, req, true)
+	if !ok {
+		return
+	}
+
+	if err := cfg.DbQueries.DeleteList(context.Background(), list.ID); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) ListMembersGetHandler(w http.ResponseWriter, req *http.Request) {
+	list, _, ok := cfg.listForRequest(w, req, false)
+	if !ok {
+		return
+	}
+
+	memberIDs, err := cfg.DbQueries.GetListMembers(context.Background(), list.ID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if memberIDs == nil {
+		memberIDs = []uuid.UUID{}
+	}
+
+	respondWithJSON(w, http.StatusOK, memberIDs)
+}
+
+func (cfg *ApiConfig) ListMemberPutHandler(w http.ResponseWriter, req *http.Request) {
+	list, ownerID, ok := cfg.listForRequest(w, req, true)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if _, err := cfg.DbQueries.GetUser(context.Background(), memberID); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	blocked, err := cfg.isBlocked(context.Background(), ownerID, memberID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You cannot add this user to a list")
+		return
+	}
+
+	tx, err := cfg.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DbQueries.WithTx(tx)
+
+	// Only the owner adds members, locking them keeps concurrent additions
+	// from all passing the limit before any of them is counted
+	if err := qtx.LockUser(context.Background(), ownerID); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	members, err := qtx.CountListMembers(context.Background(), list.ID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if members >= MAX_LIST_MEMBERS {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Lists can have at most %d members", MAX_LIST_MEMBERS))
+		return
+	}
+
+	if err := qtx.AddListMember(context.Background(), database.AddListMemberParams{
+		ListID: list.ID,
+		UserID: memberID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) ListMemberDeleteHandler(w http.ResponseWriter, req *http.Request) {
+	list, _, ok := cfg.listForRequest(w, req, true)
+	if !ok {
+		return
+	}
+
+	memberID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := cfg.DbQueries.RemoveListMember(context.Background(), database.RemoveListMemberParams{
+		ListID: list.ID,
+		UserID: memberID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) ListTimelineHandler(w http.ResponseWriter, req *http.Request) {
+	list, viewerID, ok := cfg.listForRequest(w, req, false)
+	if !ok {
+		return
+	}
+
+	before, limit, err := parsePage(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirps, err := cfg.DbQueries.GetListTimeline(context.Background(), database.GetListTimelineParams{
+		ListID:     list.ID,
+		Before:     before,
+		ViewerID:   viewerID,
+		MaxResults: limit,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	chirpsJsons, err := cfg.chirpJsons(context.Background(), viewerID, chirps)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirpsJsons)
+}
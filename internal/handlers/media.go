@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/dmitriy-zverev/chirpy/internal/media"
+	"github.com/dmitriy-zverev/chirpy/internal/storage"
+	"github.com/google/uuid"
+)
+
+type mediaJson struct {
+	Id           string `json:"id"`
+	CreatedAt    string `json:"created_at"`
+	ContentType  string `json:"content_type"`
+	SizeBytes    int64  `json:"size_bytes"`
+	Width        int32  `json:"width"`
+	Height       int32  `json:"height"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+func newMediaJson(medium database.Medium) mediaJson {
+	return mediaJson{
+		Id:           medium.ID.String(),
+		CreatedAt:    medium.CreatedAt.String(),
+		ContentType:  medium.ContentType,
+		SizeBytes:    medium.SizeBytes,
+		Width:        medium.Width,
+		Height:       medium.Height,
+		URL:          "/api/media/" + medium.ID.String(),
+		ThumbnailURL: "/api/media/" + medium.ID.String() + "/thumbnail",
+	}
+}
+
+// chirpMedia loads the media attached to chirpIDs, keyed by chirp
+func (cfg *ApiConfig) chirpMedia(ctx context.Context, chirpIDs []uuid.UUID) (map[uuid.UUID][]mediaJson, error) {
+	media, err := cfg.DbQueries.GetMediaForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaByChirp := map[uuid.UUID][]mediaJson{}
+	for _, medium := range media {
+		mediaByChirp[medium.ChirpID.UUID] = append(mediaByChirp[medium.ChirpID.UUID], newMediaJson(medium))
+	}
+	return mediaByChirp, nil
+}
+
+// mediaMaxBytes is the largest upload userID may make
+func (cfg *ApiConfig) mediaMaxBytes(ctx context.Context, userID uuid.UUID) (int64, error) {
+	isRed, err := cfg.isChirpyRed(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if isRed {
+		return RED_MEDIA_MAX_BYTES, nil
+	}
+	return FREE_MEDIA_MAX_BYTES, nil
+}
+
+func (cfg *ApiConfig) MediaPostHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	maxBytes, err := cfg.mediaMaxBytes(context.Background(), userID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	tooLarge := fmt.Sprintf("Media must be at most %d MB", maxBytes>>20)
+
+	req.Body = http.MaxBytesReader(w, req.Body, maxBytes+MULTIPART_OVERHEAD_BYTES)
+	file, _, err := req.FormFile("file")
+	if err != nil {
+		log.Printf("%v\n", err)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, "Expected an image in the file field")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if int64(len(data)) > maxBytes {
+		respondWithError(w, http.StatusRequestEntityTooLarge, tooLarge)
+		return
+	}
+
+	image, err := media.Process(data)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Only JPEG, PNG and GIF images are supported")
+		return
+	}
+	if err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid image")
+		return
+	}
+
+	mediaID := uuid.New()
+	storageKey := "media/" + mediaID.String()
+	thumbnailKey := storageKey + "_thumbnail"
+
+	if err := cfg.Blobs.Put(context.Background(), storageKey, image.Data, image.ContentType); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := cfg.Blobs.Put(context.Background(), thumbnailKey, image.Thumbnail, image.ThumbnailContentType); err != nil {
+		log.Printf("%v\n", err)
+		cfg.deleteBlobs(storageKey)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	medium, err := cfg.DbQueries.CreateMedia(context.Background(), database.CreateMediaParams{
+		ID:           mediaID,
+		UserID:       userID,
+		ContentType:  image.ContentType,
+		SizeBytes:    int64(len(image.Data)),
+		Width:        int32(image.Width),
+		Height:       int32(image.Height),
+		StorageKey:   storageKey,
+		ThumbnailKey: thumbnailKey,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		cfg.deleteBlobs(storageKey, thumbnailKey)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newMediaJson(medium))
+}
+
+// deleteBlobs cleans up blobs of an upload that didn't make it into the
+// database, logging rather than failing
+func (cfg *ApiConfig) deleteBlobs(keys ...string) {
+	for _, key := range keys {
+		if err := cfg.Blobs.Delete(context.Background(), key); err != nil {
+			log.Printf("%v\n", err)
+		}
+	}
+}
+
+func (cfg *ApiConfig) MediaGetHandler(w http.ResponseWriter, req *http.Request) {
+	cfg.serveMedia(w, req, false)
+}
+
+func (cfg *ApiConfig) MediaThumbnailGetHandler(w http.ResponseWriter, req *http.Request) {
+	cfg.serveMedia(w, req, true)
+}
+
+func (cfg *ApiConfig) serveMedia(w http.ResponseWriter, req *http.Request, thumbnail bool) {
+	mediaID, err := uuid.Parse(req.PathValue("mediaID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	viewerID, err := cfg.optionalAuthenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	medium, err := cfg.DbQueries.GetMedia(context.Background(), mediaID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	visible, public, err := cfg.canViewMedia(context.Background(), medium, viewerID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !visible {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	key, contentType := medium.StorageKey, medium.ContentType
+	if thumbnail {
+		key, contentType = medium.ThumbnailKey, media.ThumbnailContentType(medium.ContentType)
+	}
+
+	blob, err := cfg.Blobs.Get(context.Background(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+
+	// Media never changes once uploaded, but its chirp can be deleted, so
+	// caches only keep it for a while. Media of chirps that aren't public
+	// must not be kept by shared caches.
+	cacheControl := "private, max-age=3600, immutable"
+	if public {
+		cacheControl = "public, max-age=3600, immutable"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("Vary", "Authorization")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, blob); err != nil {
+		log.Printf("%v\n", err)
+	}
+}
+
+// canViewMedia applies the visibility of the chirp medium is attached to,
+// and reports whether the viewer may see it and whether it is public.
+// Uploads not attached to a chirp yet are only shown to their uploader.
+func (cfg *ApiConfig) canViewMedia(ctx context.Context, medium database.Medium, viewerID uuid.UUID) (visible bool, public bool, err error) {
+	if !medium.ChirpID.Valid {
+		return viewerID != uuid.Nil && viewerID == medium.UserID, false, nil
+	}
+
+	chirp, err := cfg.DbQueries.GetVisibleChirp(ctx, database.GetVisibleChirpParams{
+		ID:       medium.ChirpID.UUID,
+		ViewerID: viewerID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+
+	blocked, err := cfg.isBlocked(ctx, viewerID, chirp.UserID)
+	if err != nil || blocked {
+		return false, false, err
+	}
+
+	return true, chirp.Visibility == CHIRP_VISIBILITY_PUBLIC, nil
+}
@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type messageJson struct {
+	Id             string `json:"id"`
+	CreatedAt      string `json:"created_at"`
+	ConversationID string `json:"conversation_id"`
+	SenderID       string `json:"sender_id"`
+	Body           string `json:"body"`
+}
+
+func newMessageJson(message database.Message) messageJson {
+	return messageJson{
+		Id:             message.ID.String(),
+		CreatedAt:      message.CreatedAt.String(),
+		ConversationID: message.ConversationID.String(),
+		SenderID:       message.SenderID.String(),
+		Body:           message.Body,
+	}
+}
+
+type conversationJson struct {
+	Id             string       `json:"id"`
+	CreatedAt      string       `json:"created_at"`
+	UpdatedAt      string       `json:"updated_at"`
+	ParticipantIDs []uuid.UUID  `json:"participant_ids"`
+	LastMessage    *messageJson `json:"last_message"`
+}
+
+// validateMessageBody returns a user facing error for an unsendable body
+func validateMessageBody(body string) error {
+	if body == "" {
+		return errors.New("Message body is required")
+	}
+	if len([]rune(body)) > MAX_MESSAGE_LENGTH {
+		return fmt.Errorf("Message is longer than %d characters", MAX_MESSAGE_LENGTH)
+	}
+	return nil
+}
+
+// blockedInConversation reports whether senderID has a block with any of
+// the participants
+func (cfg *ApiConfig) blockedInConversation(ctx context.Context, senderID uuid.UUID, participantIDs []uuid.UUID) (bool, error) {
+	for _, participantID := range participantIDs {
+		blocked, err := cfg.isBlocked(ctx, senderID, participantID)
+		if err != nil || blocked {
+			return blocked, err
+		}
+	}
+	return false, nil
+}
+
+func (cfg *ApiConfig) ConversationsPostHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		ParticipantIDs []uuid.UUID `json:"participant_ids"`
+		Body           string      `json:"body"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	participantIDs := []uuid.UUID{}
+	for _, participantID := range params.ParticipantIDs {
+		if participantID != userID && !slices.Contains(participantIDs, participantID) {
+			participantIDs = append(participantIDs, participantID)
+		}
+	}
+	if len(participantIDs) == 0 || len(participantIDs) >= MAX_CONVERSATION_PARTICIPANTS {
+		respondWithError(
+			w,
+			http.StatusBadRequest,
+			fmt.Sprintf("A conversation needs 1 to %d other participants", MAX_CONVERSATION_PARTICIPANTS-1),
+		)
+		return
+	}
+
+	if params.Body != "" {
+		if err := validateMessageBody(params.Body); err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	for _, participantID := range participantIDs {
+		if _, err := cfg.DbQueries.GetUser(context.Background(), participantID); err != nil {
+			log.Printf("%v\n", err)
+			respondWithError(w, http.StatusNotFound, "Participant not found")
+			return
+		}
+	}
+
+	blocked, err := cfg.blockedInConversation(context.Background(), userID, participantIDs)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You cannot message this user")
+		return
+	}
+
+	// One-to-one conversations are reused rather than duplicated
+	if len(participantIDs) == 1 {
+		conversation, err := cfg.DbQueries.GetDirectConversation(
+			context.Background(),
+			database.GetDirectConversationParams{
+				UserID:      userID,
+				OtherUserID: participantIDs[0],
+			},
+		)
+		if err == nil {
+			cfg.respondWithConversation(w, http.StatusOK, conversation, userID, params.Body)
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("%v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	tx, err := cfg.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DbQueries.WithTx(tx)
+
+	// Locking the sender makes concurrent requests take turns, so they
+	// can't all pass the daily limit before any of them is counted
+	if err := qtx.LockUser(context.Background(), userID); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	isRed, err := cfg.isChirpyRed(context.Background(), userID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !isRed {
+		startedToday, err := qtx.CountConversationsCreatedSince(
+			context.Background(),
+			database.CountConversationsCreatedSinceParams{
+				CreatedBy: userID,
+				CreatedAt: time.Now().UTC().Add(-24 * time.Hour),
+			},
+		)
+		if err != nil {
+			log.Printf("%v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if startedToday >= FREE_DAILY_CONVERSATIONS {
+			respondWithError(
+				w,
+				http.StatusTooManyRequests,
+				fmt.Sprintf("Free accounts can start %d conversations a day, upgrade to Chirpy Red for more", FREE_DAILY_CONVERSATIONS),
+			)
+			return
+		}
+	}
+
+	conversation, err := qtx.CreateConversation(context.Background(), userID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	for _, participantID := range append(participantIDs, userID) {
+		if err := qtx.AddConversationParticipant(
+			context.Background(),
+			database.AddConversationParticipantParams{
+				ConversationID: conversation.ID,
+				UserID:         participantID,
+			},
+		); err != nil {
+			log.Printf("%v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	cfg.respondWithConversation(w, http.StatusCreated, conversation, userID, params.Body)
+}
+
+// respondWithConversation optionally sends body as the first message and
+// writes the conversation JSON
+func (cfg *ApiConfig) respondWithConversation(w http.ResponseWriter, code int, conversation database.Conversation, senderID uuid.UUID, body string) {
+	var lastMessage *messageJson
+	if body != "" {
+		message, err := cfg.sendMessage(context.Background(), conversation.ID, senderID, body)
+		if err != nil {
+			log.Printf("%v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		messageResp := newMessageJson(message)
+		lastMessage = &messageResp
+	}
+
+	participantIDs, err := cfg.DbQueries.GetConversationParticipants(context.Background(), conversation.ID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, code, conversationJson{
+		Id:             conversation.ID.String(),
+		CreatedAt:      conversation.CreatedAt.String(),
+		UpdatedAt:      conversation.UpdatedAt.String(),
+		ParticipantIDs: participantIDs,
+		LastMessage:    lastMessage,
+	})
+}
+
+func (cfg *ApiConfig) sendMessage(ctx context.Context, conversationID, senderID uuid.UUID, body string) (database.Message, error) {
+	tx, err := cfg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Message{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DbQueries.WithTx(tx)
+
+	message, err := qtx.CreateMessage(ctx, database.CreateMessageParams{
+		ConversationID: conversationID,
+		SenderID:       senderID,
+		Body:           body,
+	})
+	if err != nil {
+		return database.Message{}, err
+	}
+
+	if err := qtx.TouchConversation(ctx, conversationID); err != nil {
+		return database.Message{}, err
+	}
+
+	return message, tx.Commit()
+}
+
+// conversationForParticipant authenticates the caller and resolves the
+// {conversationID} path value, answering 404 to non-participants so
+// conversations of other users stay invisible
+func (cfg *ApiConfig) conversationForParticipant(w http.ResponseWriter, req *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	conversationID, err := uuid.Parse(req.PathValue("conversationID"))
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	isParticipant, err := cfg.DbQueries.IsConversationParticipant(
+		context.Background(),
+		database.IsConversationParticipantParams{
+			ConversationID: conversationID,
+			UserID:         userID,
+		},
+	)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+	if !isParticipant {
+		w.WriteHeader(http.StatusNotFound)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	return userID, conversationID, true
+}
+
+func (cfg *ApiConfig) MessagesPostHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	userID, conversationID, ok := cfg.conversationForParticipant(w, req)
+	if !ok {
+		return
+	}
+
+	if err := validateMessageBody(params.Body); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	participantIDs, err := cfg.DbQueries.GetConversationParticipants(context.Background(), conversationID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	blocked, err := cfg.blockedInConversation(context.Background(), userID, participantIDs)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "You cannot message this conversation")
+		return
+	}
+
+	message, err := cfg.sendMessage(context.Background(), conversationID, userID, params.Body)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newMessageJson(message))
+}
+
+func (cfg *ApiConfig) ConversationsGetHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	before, limit, err := parsePage(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, err := cfg.DbQueries.GetConversations(context.Background(), database.GetConversationsParams{
+		UserID:     userID,
+		Before:     before,
+		MaxResults: limit,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	conversations := []conversationJson{}
+	for _, row := range rows {
+		conversation := conversationJson{
+			Id:             row.ID.String(),
+			CreatedAt:      row.CreatedAt.String(),
+			UpdatedAt:      row.UpdatedAt.String(),
+			ParticipantIDs: row.ParticipantIds,
+		}
+		if row.LastMessageID.Valid {
+			conversation.LastMessage = &messageJson{
+				Id:             row.LastMessageID.UUID.String(),
+				CreatedAt:      row.LastMessageCreatedAt.Time.String(),
+				ConversationID: row.ID.String(),
+				SenderID:       row.LastMessageSenderID.UUID.String(),
+				Body:           row.LastMessageBody.String,
+			}
+		}
+		conversations = append(conversations, conversation)
+	}
+
+	respondWithJSON(w, http.StatusOK, conversations)
+}
+
+func (cfg *ApiConfig) MessagesGetHandler(w http.ResponseWriter, req *http.Request) {
+	_, conversationID, ok := cfg.conversationForParticipant(w, req)
+	if !ok {
+		return
+	}
+
+	before, limit, err := parsePage(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	messages, err := cfg.DbQueries.GetMessages(context.Background(), database.GetMessagesParams{
+		ConversationID: conversationID,
+		CreatedAt:      before,
+		Limit:          limit,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	messagesJsons := []messageJson{}
+	for _, message := range messages {
+		messagesJsons = append(messagesJsons, newMessageJson(message))
+	}
+
+	respondWithJSON(w, http.StatusOK, messagesJsons)
+}
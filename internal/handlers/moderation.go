@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/dmitriy-zverev/chirpy/internal/events"
+	"github.com/dmitriy-zverev/chirpy/internal/moderation"
+	"github.com/google/uuid"
+)
+
+type moderationRuleJson struct {
+	Id        string `json:"id"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	Kind      string `json:"kind"`
+	Pattern   string `json:"pattern"`
+	Action    string `json:"action"`
+}
+
+func newModerationRuleJson(rule database.ModerationRule) moderationRuleJson {
+	return moderationRuleJson{
+		Id:        rule.ID.String(),
+		CreatedAt: rule.CreatedAt.String(),
+		UpdatedAt: rule.UpdatedAt.String(),
+		Kind:      rule.Kind,
+		Pattern:   rule.Pattern,
+		Action:    rule.Action,
+	}
+}
+
+type moderationRuleParameters struct {
+	Kind    string `json:"kind"`
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+}
+
+func (params moderationRuleParameters) validate() error {
+	return moderation.Validate(moderation.Rule{
+		Kind:    moderation.Kind(params.Kind),
+		Pattern: params.Pattern,
+		Action:  moderation.Action(params.Action),
+	})
+}
+
+// LoadModerationRules reloads the moderation engine from the database
+func (cfg *ApiConfig) LoadModerationRules(ctx context.Context) error {
+	rows, err := cfg.DbQueries.GetModerationRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	rules := make([]moderation.Rule, 0, len(rows))
+	for _, row := range rows {
+		rules = append(rules, moderation.Rule{
+			ID:      row.ID,
+			Kind:    moderation.Kind(row.Kind),
+			Pattern: row.Pattern,
+			Action:  moderation.Action(row.Action),
+		})
+	}
+
+	return cfg.Moderation.Load(rules)
+}
+
+// RunModerationReloader reloads the moderation rules every interval until
+// ctx is done, so rule changes made through another replica take effect
+// without a restart. Changes made through this one apply immediately.
+func (cfg *ApiConfig) RunModerationReloader(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cfg.LoadModerationRules(ctx); err != nil {
+				log.Printf("moderation: %v\n", err)
+			}
+		}
+	}
+}
+
+func (cfg *ApiConfig) reloadModerationRules() {
+	if err := cfg.LoadModerationRules(context.Background()); err != nil {
+		log.Printf("moderation: %v\n", err)
+	}
+}
+
+func (cfg *ApiConfig) ModerationRulesGetHandler(w http.ResponseWriter, req *http.Request) {
+	if _, ok := cfg.authenticateAdmin(w, req); !ok {
+		return
+	}
+
+	rules, err := cfg.DbQueries.GetModerationRules(context.Background())
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rulesJsons := []moderationRuleJson{}
+	for _, rule := range rules {
+		rulesJsons = append(rulesJsons, newModerationRuleJson(rule))
+	}
+
+	respondWithJSON(w, http.StatusOK, rulesJsons)
+}
+
+func (cfg *ApiConfig) ModerationRulesPostHandler(w http.ResponseWriter, req *http.Request) {
+	params := moderationRuleParameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if _, ok := cfg.authenticateAdmin(w, req); !ok {
+		return
+	}
+
+	if err := params.validate(); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rule, err := cfg.DbQueries.CreateModerationRule(context.Background(), database.CreateModerationRuleParams{
+		Kind:    params.Kind,
+		Pattern: params.Pattern,
+		Action:  params.Action,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	cfg.reloadModerationRules()
+	respondWithJSON(w, http.StatusCreated, newModerationRuleJson(rule))
+}
+
+func (cfg *ApiConfig) ModerationRulePutHandler(w http.ResponseWriter, req *http.Request) {
+	params := moderationRuleParameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if _, ok := cfg.authenticateAdmin(w, req); !ok {
+		return
+	}
+
+	ruleID, err := uuid.Parse(req.PathValue("ruleID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if err := params.validate(); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rule, err := cfg.DbQueries.UpdateModerationRule(context.Background(), database.UpdateModerationRuleParams{
+		ID:      ruleID,
+		Kind:    params.Kind,
+		Pattern: params.Pattern,
+		Action:  params.Action,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	cfg.reloadModerationRules()
+	respondWithJSON(w, http.StatusOK, newModerationRuleJson(rule))
+}
+
+func (cfg *ApiConfig) ModerationRuleDeleteHandler(w http.ResponseWriter, req *http.Request) {
+	if _, ok := cfg.authenticateAdmin(w, req); !ok {
+		return
+	}
+
+	ruleID, err := uuid.Parse(req.PathValue("ruleID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	deleted, err := cfg.DbQueries.DeleteModerationRule(context.Background(), ruleID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	cfg.reloadModerationRules()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type heldChirpJson struct {
+	Id        string           `json:"id"`
+	CreatedAt string           `json:"created_at"`
+	UserID    string           `json:"user_id"`
+	Body      string           `json:"body"`
+	Chirp     heldChirpPayload `json:"chirp"`
+}
+
+func (cfg *ApiConfig) HeldChirpsGetHandler(w http.ResponseWriter, req *http.Request) {
+	if _, ok := cfg.authenticateAdmin(w, req); !ok {
+		return
+	}
+
+	heldChirps, err := cfg.DbQueries.GetHeldChirps(context.Background())
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	heldJsons := []heldChirpJson{}
+	for _, held := range heldChirps {
+		heldJson := heldChirpJson{
+			Id:        held.ID.String(),
+			CreatedAt: held.CreatedAt.String(),
+			UserID:    held.UserID.String(),
+			Body:      held.Body,
+		}
+		if err := json.Unmarshal(held.Payload, &heldJson.Chirp); err != nil {
+			log.Printf("%v\n", err)
+		}
+		heldJsons = append(heldJsons, heldJson)
+	}
+
+	respondWithJSON(w, http.StatusOK, heldJsons)
+}
+
+// HeldChirpApproveHandler publishes a held chirp. Masking rules still
+// apply, it's only the hold that the moderator lifts.
+func (cfg *ApiConfig) HeldChirpApproveHandler(w http.ResponseWriter, req *http.Request) {
+	if _, ok := cfg.authenticateAdmin(w, req); !ok {
+		return
+	}
+
+	heldID, err := uuid.Parse(req.PathValue("heldID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	tx, err := cfg.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DbQueries.WithTx(tx)
+
+	// Claimed in the transaction, a failed insert puts it back
+	held, err := qtx.ClaimHeldChirp(context.Background(), heldID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	payload := heldChirpPayload{}
+	if err := json.Unmarshal(held.Payload, &payload); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	moderated, _ := cfg.moderateChirp(payload.chirp())
+	chirp, err := cfg.insertChirp(context.Background(), qtx, held.UserID, moderated)
+	if err != nil {
+		var validationErr *chirpValidationError
+		if errors.As(err, &validationErr) {
+			respondWithChirpValidationError(w, http.StatusConflict, validationErr)
+			return
+		}
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	cfg.Fanout.Enqueue(chirp)
+	cfg.publishChirpEvent(context.Background(), events.KindChirpCreated, chirp)
+
+	chirpResp, err := cfg.chirpJson(context.Background(), uuid.Nil, chirp)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, chirpResp)
+}
+
+func (cfg *ApiConfig) HeldChirpDeleteHandler(w http.ResponseWriter, req *http.Request) {
+	if _, ok := cfg.authenticateAdmin(w, req); !ok {
+		return
+	}
+
+	heldID, err := uuid.Parse(req.PathValue("heldID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	deleted, err := cfg.DbQueries.DeleteHeldChirp(context.Background(), heldID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
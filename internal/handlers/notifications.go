@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/dmitriy-zverev/chirpy/internal/events"
+	"github.com/google/uuid"
+)
+
+// notify records a notification for userID about something actorID did.
+// actorID is uuid.Nil for system events and chirpID is uuid.Nil for events
+// not tied to a chirp. Failures are logged and never fail the caller.
+func (cfg *ApiConfig) notify(ctx context.Context, userID, actorID uuid.UUID, kind string, chirpID uuid.UUID) {
+	if userID == actorID {
+		return
+	}
+
+	blocked, err := cfg.isBlocked(ctx, userID, actorID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		return
+	}
+	if blocked {
+		return
+	}
+
+	notification, err := cfg.DbQueries.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  userID,
+		ActorID: uuid.NullUUID{UUID: actorID, Valid: actorID != uuid.Nil},
+		Kind:    kind,
+		ChirpID: uuid.NullUUID{UUID: chirpID, Valid: chirpID != uuid.Nil},
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		return
+	}
+
+	// Tell the user's open WebSocket connections, on whichever replica
+	if err := cfg.Events.Publish(ctx, events.Event{
+		Kind:           events.KindNotificationCreated,
+		ChirpID:        chirpID,
+		UserID:         userID,
+		NotificationID: notification.ID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+	}
+}
+
+// notifyMentions notifies the users chirp mentions, except those who
+// muted its author; mentions across a block were never stored. q may be
+// bound to the transaction creating the chirp, the notifications and their
+// events then only go out if it commits.
+func (cfg *ApiConfig) notifyMentions(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	notifications, err := q.CreateMentionNotifications(ctx, database.CreateMentionNotificationsParams{
+		ActorID: chirp.UserID,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, notification := range notifications {
+		if err := cfg.Events.PublishWith(ctx, q, events.Event{
+			Kind:           events.KindNotificationCreated,
+			ChirpID:        chirp.ID,
+			UserID:         notification.UserID,
+			NotificationID: notification.ID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notificationMessage renders a group of notifications, e.g.
+// "5 people liked your chirp"
+func notificationMessage(kind string, actors int64) string {
+	who := "Someone"
+	if actors > 1 {
+		who = fmt.Sprintf("%d people", actors)
+	}
+
+	switch kind {
+	case NOTIFICATION_POLL_CLOSED:
+		return "Your poll has ended"
+	case NOTIFICATION_MENTION:
+		return who + " mentioned you"
+	case NOTIFICATION_FOLLOW:
+		return who + " followed you"
+	default:
+		return who + " interacted with you"
+	}
+}
+
+func (cfg *ApiConfig) NotificationsGetHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	before, limit, err := parsePage(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	groups, err := cfg.DbQueries.GetNotificationGroups(context.Background(), database.GetNotificationGroupsParams{
+		UserID:     userID,
+		Before:     before,
+		MaxResults: limit,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	unreadCount, err := cfg.DbQueries.CountUnreadNotifications(context.Background(), userID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	type notificationJson struct {
+		Kind          string  `json:"kind"`
+		Message       string  `json:"message"`
+		ChirpID       *string `json:"chirp_id"`
+		Actors        int64   `json:"actors"`
+		Unread        int64   `json:"unread"`
+		LatestActorID *string `json:"latest_actor_id"`
+		LatestAt      string  `json:"latest_at"`
+		Cursor        string  `json:"cursor"`
+	}
+
+	notifications := []notificationJson{}
+	for _, group := range groups {
+		notification := notificationJson{
+			Kind:     group.Kind,
+			Message:  notificationMessage(group.Kind, group.Actors),
+			Actors:   group.Actors,
+			Unread:   group.Unread,
+			LatestAt: group.LatestAt.Format(time.RFC3339Nano),
+			// A group only gains members on the day it started, so its
+			// start is a key pages can be cut on
+			Cursor: group.StartedAt.Format(time.RFC3339Nano),
+		}
+		if group.ChirpID.Valid {
+			chirpID := group.ChirpID.UUID.String()
+			notification.ChirpID = &chirpID
+		}
+		if group.LatestActorID != uuid.Nil {
+			latestActorID := group.LatestActorID.String()
+			notification.LatestActorID = &latestActorID
+		}
+		notifications = append(notifications, notification)
+	}
+
+	respondWithJSON(w, http.StatusOK, struct {
+		UnreadCount   int64              `json:"unread_count"`
+		Notifications []notificationJson `json:"notifications"`
+	}{
+		UnreadCount:   unreadCount,
+		Notifications: notifications,
+	})
+}
+
+func (cfg *ApiConfig) NotificationsReadHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		UpTo string `json:"up_to"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	upTo := time.Now().UTC()
+	if params.UpTo != "" {
+		upTo, err = time.Parse(time.RFC3339Nano, params.UpTo)
+		if err != nil {
+			log.Printf("%v\n", err)
+			respondWithError(w, http.StatusBadRequest, "Invalid up_to")
+			return
+		}
+	}
+
+	if err := cfg.DbQueries.MarkNotificationsRead(context.Background(), database.MarkNotificationsReadParams{
+		UserID:    userID,
+		CreatedAt: upTo,
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
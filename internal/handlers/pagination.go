@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// parsePage reads the `before` (RFC 3339) and `limit` query parameters
+// used by every cursor-paginated listing
+func parsePage(req *http.Request) (time.Time, int32, error) {
+	before := time.Now().UTC().Add(time.Minute)
+	if beforeValue := req.URL.Query().Get("before"); beforeValue != "" {
+		parsedBefore, err := time.Parse(time.RFC3339Nano, beforeValue)
+		if err != nil {
+			return time.Time{}, 0, fmt.Errorf("invalid before cursor: %w", err)
+		}
+		before = parsedBefore.UTC()
+	}
+
+	limit := DEFAULT_PAGE_SIZE
+	if limitValue := req.URL.Query().Get("limit"); limitValue != "" {
+		parsedLimit, err := strconv.Atoi(limitValue)
+		if err != nil || parsedLimit < 1 {
+			return time.Time{}, 0, fmt.Errorf("invalid limit: %s", limitValue)
+		}
+		limit = min(parsedLimit, MAX_PAGE_SIZE)
+	}
+
+	return before, int32(limit), nil
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// pinnedFirst orders an author's chirps with the pinned ones on top, in
+// the order they were pinned, followed by the rest in their given order
+func pinnedFirst(chirps []chirpJson, pinnedIDs []uuid.UUID) []chirpJson {
+	pinned := make([]chirpJson, len(pinnedIDs))
+	found := make([]bool, len(pinnedIDs))
+	rest := []chirpJson{}
+
+	for _, chirpResp := range chirps {
+		i := slices.IndexFunc(pinnedIDs, func(id uuid.UUID) bool {
+			return id.String() == chirpResp.Id
+		})
+		if i >= 0 {
+			isPinned := true
+			chirpResp.Pinned = &isPinned
+			pinned[i] = chirpResp
+			found[i] = true
+			continue
+		}
+		isPinned := false
+		chirpResp.Pinned = &isPinned
+		rest = append(rest, chirpResp)
+	}
+
+	chirpsJsons := []chirpJson{}
+	for i, chirpResp := range pinned {
+		if found[i] {
+			chirpsJsons = append(chirpsJsons, chirpResp)
+		}
+	}
+	return append(chirpsJsons, rest...)
+}
+
+// ownChirp authenticates the caller and resolves the {chirpID} path value
+// to one of the caller's chirps
+func (cfg *ApiConfig) ownChirp(w http.ResponseWriter, req *http.Request) (database.Chirp, bool) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return database.Chirp{}, false
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return database.Chirp{}, false
+	}
+
+	chirp, err := cfg.DbQueries.GetVisibleChirp(
+		context.Background(),
+		database.GetVisibleChirpParams{
+			ID:       chirpID,
+			ViewerID: userID,
+		},
+	)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return database.Chirp{}, false
+	}
+
+	if chirp.UserID != userID {
+		w.WriteHeader(http.StatusForbidden)
+		return database.Chirp{}, false
+	}
+
+	return chirp, true
+}
+
+func (cfg *ApiConfig) PinPutHandler(w http.ResponseWriter, req *http.Request) {
+	chirp, ok := cfg.ownChirp(w, req)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.DB.BeginTx(context.Background(), nil)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.DbQueries.WithTx(tx)
+
+	// Locking the user keeps concurrent pins from all passing the limit
+	// before any of them is counted
+	if err := qtx.LockUser(context.Background(), chirp.UserID); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	pinnedIDs, err := qtx.GetPinnedChirpIDs(context.Background(), chirp.UserID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if slices.Contains(pinnedIDs, chirp.ID) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	isRed, err := cfg.isChirpyRed(context.Background(), chirp.UserID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	maxPins := FREE_PINNED_CHIRPS
+	if isRed {
+		maxPins = RED_PINNED_CHIRPS
+	}
+	if len(pinnedIDs) >= maxPins {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("You can pin at most %d chirps", maxPins))
+		return
+	}
+
+	if err := qtx.PinChirp(context.Background(), database.PinChirpParams{
+		UserID:  chirp.UserID,
+		ChirpID: chirp.ID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) PinDeleteHandler(w http.ResponseWriter, req *http.Request) {
+	chirp, ok := cfg.ownChirp(w, req)
+	if !ok {
+		return
+	}
+
+	if err := cfg.DbQueries.UnpinChirp(context.Background(), database.UnpinChirpParams{
+		UserID:  chirp.UserID,
+		ChirpID: chirp.ID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/auth"
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+var (
+	errPolkaInvalidPayload       = errors.New("invalid payload")
+	errPolkaUserNotFound         = errors.New("user not found")
+	errPolkaSubscriptionNotFound = errors.New("user has no subscription")
+)
+
+// authenticatePolka accepts a request signed with one of the Polka
+// secrets or, for senders that don't sign yet, carrying the Polka API key.
+// A signed request must have a valid signature, it never falls back to
+// the API key.
+func (cfg *ApiConfig) authenticatePolka(headers http.Header, body []byte) error {
+	if signature := headers.Get(POLKA_SIGNATURE_HEADER); signature != "" {
+		return auth.ValidateSignature(
+			signature,
+			headers.Get(POLKA_TIMESTAMP_HEADER),
+			body,
+			cfg.PolkaSecrets,
+			cfg.PolkaSignatureTolerance,
+			time.Now(),
+		)
+	}
+
+	return auth.CheckAPIKey(headers, cfg.PolkaKey)
+}
+
+// polkaEventID is the key events are deduplicated on: the ID Polka gave
+// the event, or a hash of the payload for events sent without one, so a
+// redelivery of the same body is still recognized
+func polkaEventID(id string, body []byte) string {
+	if id != "" {
+		return id
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// PolkaHookPostHandler records every authenticated event in the event log
+// before processing it. Redeliveries of an event that was processed or
+// ignored are acknowledged without doing anything; a failed event is
+// processed again.
+func (cfg *ApiConfig) PolkaHookPostHandler(w http.ResponseWriter, req *http.Request) {
+	// Signatures cover the raw body, so it is read before decoding
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, MAX_POLKA_BODY_BYTES))
+	if err != nil {
+		log.Printf("%v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := cfg.authenticatePolka(req.Header, body); err != nil {
+		log.Printf("%v", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	// A payload that doesn't decode is still logged, it fails when it is
+	// processed
+	envelope := struct {
+		ID    string `json:"id"`
+		Event string `json:"event"`
+	}{}
+	json.Unmarshal(body, &envelope)
+	if len(envelope.ID) > MAX_POLKA_EVENT_ID_LENGTH {
+		respondWithError(w, http.StatusBadRequest, "Event ID is too long")
+		return
+	}
+
+	event, err := cfg.DbQueries.RecordPolkaEvent(context.Background(), database.RecordPolkaEventParams{
+		ID:          polkaEventID(envelope.ID, body),
+		Event:       envelope.Event,
+		Payload:     string(body),
+		StaleBefore: time.Now().UTC().Add(-POLKA_EVENT_STALE_AFTER),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// Already processed or ignored, or being processed right now
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := cfg.processPolkaEvent(context.Background(), event); err != nil {
+		log.Printf("polka event %s: %v\n", event.ID, err)
+		switch {
+		case errors.Is(err, errPolkaInvalidPayload):
+			w.WriteHeader(http.StatusBadRequest)
+		case errors.Is(err, errPolkaUserNotFound), errors.Is(err, errPolkaSubscriptionNotFound):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// processPolkaEvent applies event and records how it went, in the same
+// transaction as its changes when it succeeds. It returns the event as it
+// was left, along with the error that failed it.
+func (cfg *ApiConfig) processPolkaEvent(ctx context.Context, event database.PolkaEvent) (database.PolkaEvent, error) {
+	tx, err := cfg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return cfg.failPolkaEvent(ctx, event, err)
+	}
+	defer tx.Rollback()
+	qtx := cfg.DbQueries.WithTx(tx)
+
+	status, err := applyPolkaEvent(ctx, qtx, event)
+	if err != nil {
+		tx.Rollback()
+		return cfg.failPolkaEvent(ctx, event, err)
+	}
+
+	finished, err := qtx.FinishPolkaEvent(ctx, database.FinishPolkaEventParams{
+		Status: status,
+		ID:     event.ID,
+	})
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		tx.Rollback()
+		return cfg.failPolkaEvent(ctx, event, err)
+	}
+
+	return finished, nil
+}
+
+// failPolkaEvent records why event failed, so it can be looked into and
+// replayed once the cause is fixed
+func (cfg *ApiConfig) failPolkaEvent(ctx context.Context, event database.PolkaEvent, cause error) (database.PolkaEvent, error) {
+	message := cause.Error()
+	message = message[:min(len(message), MAX_POLKA_EVENT_ERROR_LENGTH)]
+
+	failed, err := cfg.DbQueries.FinishPolkaEvent(ctx, database.FinishPolkaEventParams{
+		Status: POLKA_EVENT_FAILED,
+		Error:  sql.NullString{String: message, Valid: true},
+		ID:     event.ID,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		return event, cause
+	}
+	return failed, cause
+}
+
+// applyPolkaEvent makes the changes event calls for and returns the
+// status to record for it. Events Chirpy doesn't handle are ignored.
+func applyPolkaEvent(ctx context.Context, q *database.Queries, event database.PolkaEvent) (string, error) {
+	payload := struct {
+		Event string `json:"event"`
+		Data  struct {
+			UserID           uuid.UUID  `json:"user_id"`
+			Plan             string     `json:"plan"`
+			CurrentPeriodEnd *time.Time `json:"current_period_end"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal([]byte(event.Payload), &payload); err != nil {
+		return "", fmt.Errorf("%w: %v", errPolkaInvalidPayload, err)
+	}
+	if len(payload.Data.Plan) > MAX_SUBSCRIPTION_PLAN_LENGTH {
+		return "", fmt.Errorf("%w: plan is too long", errPolkaInvalidPayload)
+	}
+
+	switch payload.Event {
+	case POLKA_USER_UPGRADED, POLKA_USER_RENEWED, POLKA_USER_DOWNGRADED, POLKA_PAYMENT_FAILED:
+	default:
+		return POLKA_EVENT_IGNORED, nil
+	}
+
+	userID := payload.Data.UserID
+	if _, err := q.GetUser(ctx, userID); errors.Is(err, sql.ErrNoRows) {
+		return "", errPolkaUserNotFound
+	} else if err != nil {
+		return "", err
+	}
+
+	var err error
+	switch payload.Event {
+	case POLKA_USER_UPGRADED, POLKA_USER_RENEWED:
+		_, err = activateSubscription(ctx, q, payload.Event, userID, payload.Data.Plan, payload.Data.CurrentPeriodEnd)
+	case POLKA_USER_DOWNGRADED:
+		// The user keeps Chirpy Red until the end of the period
+		_, err = q.CancelSubscription(ctx, userID)
+	case POLKA_PAYMENT_FAILED:
+		_, err = q.MarkSubscriptionPastDue(ctx, userID)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errPolkaSubscriptionNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if payload.Event == POLKA_USER_UPGRADED {
+		if err := enqueueWebhook(ctx, q, WEBHOOK_EVENT_USER_UPGRADED, userID, struct {
+			UserID string `json:"user_id"`
+		}{
+			UserID: userID.String(),
+		}); err != nil {
+			return "", err
+		}
+	}
+
+	return POLKA_EVENT_PROCESSED, nil
+}
+
+type polkaEventJson struct {
+	Id          string          `json:"id"`
+	ReceivedAt  string          `json:"received_at"`
+	UpdatedAt   string          `json:"updated_at"`
+	Event       string          `json:"event"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	Error       *string         `json:"error"`
+	ProcessedAt *string         `json:"processed_at"`
+}
+
+func newPolkaEventJson(event database.PolkaEvent) polkaEventJson {
+	resp := polkaEventJson{
+		Id:         event.ID,
+		ReceivedAt: event.ReceivedAt.Format(time.RFC3339Nano),
+		UpdatedAt:  event.UpdatedAt.Format(time.RFC3339),
+		Event:      event.Event,
+		Payload:    json.RawMessage(event.Payload),
+		Status:     event.Status,
+		Attempts:   event.Attempts,
+	}
+	// Payloads that aren't JSON are logged too, they are shown as a string
+	if !json.Valid([]byte(event.Payload)) {
+		resp.Payload, _ = json.Marshal(event.Payload)
+	}
+	if event.Error.Valid {
+		resp.Error = &event.Error.String
+	}
+	if event.ProcessedAt.Valid {
+		processedAt := event.ProcessedAt.Time.Format(time.RFC3339)
+		resp.ProcessedAt = &processedAt
+	}
+	return resp
+}
+
+// PolkaEventsGetHandler lists the Polka event log, newest first,
+// optionally only the events with a given status
+func (cfg *ApiConfig) PolkaEventsGetHandler(w http.ResponseWriter, req *http.Request) {
+	if _, ok := cfg.authenticateAdmin(w, req); !ok {
+		return
+	}
+
+	status := req.URL.Query().Get("status")
+	if status != "" && status != POLKA_EVENT_PROCESSING && status != POLKA_EVENT_PROCESSED && status != POLKA_EVENT_IGNORED && status != POLKA_EVENT_FAILED {
+		respondWithError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	before, limit, err := parsePage(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	polkaEvents, err := cfg.DbQueries.GetPolkaEvents(context.Background(), database.GetPolkaEventsParams{
+		Status:     status,
+		Before:     before,
+		MaxResults: limit,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	eventJsons := []polkaEventJson{}
+	for _, event := range polkaEvents {
+		eventJsons = append(eventJsons, newPolkaEventJson(event))
+	}
+
+	respondWithJSON(w, http.StatusOK, eventJsons)
+}
+
+// PolkaEventReplayHandler processes a failed event again, after whatever
+// failed it has been fixed. The event is returned as it was left, so a
+// replay that fails again shows the new error.
+func (cfg *ApiConfig) PolkaEventReplayHandler(w http.ResponseWriter, req *http.Request) {
+	if _, ok := cfg.authenticateAdmin(w, req); !ok {
+		return
+	}
+
+	event, err := cfg.DbQueries.ReplayPolkaEvent(context.Background(), req.PathValue("eventID"))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "No failed event with this ID")
+		return
+	}
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	event, err = cfg.processPolkaEvent(context.Background(), event)
+	if err != nil {
+		log.Printf("polka event %s: %v\n", event.ID, err)
+	}
+
+	respondWithJSON(w, http.StatusOK, newPolkaEventJson(event))
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/dmitriy-zverev/chirpy/internal/events"
+	"github.com/google/uuid"
+)
+
+// formatPollDuration spells out a duration in the largest whole unit, for
+// error messages
+func formatPollDuration(d time.Duration) string {
+	count, unit := int64(d/time.Minute), "minute"
+	switch {
+	case d%(24*time.Hour) == 0:
+		count, unit = int64(d/(24*time.Hour)), "day"
+	case d%time.Hour == 0:
+		count, unit = int64(d/time.Hour), "hour"
+	}
+	if count != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", count, unit)
+}
+
+// newPoll is a poll submitted along with a chirp
+type newPoll struct {
+	Options   []string  `json:"options"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// validate returns the trimmed options, or a validation error for the author
+func (poll newPoll) validate() ([]string, error) {
+	if len(poll.Options) < MIN_POLL_OPTIONS || len(poll.Options) > MAX_POLL_OPTIONS {
+		return nil, &chirpValidationError{
+			Message: fmt.Sprintf("A poll needs %d to %d options", MIN_POLL_OPTIONS, MAX_POLL_OPTIONS),
+		}
+	}
+
+	options := make([]string, 0, len(poll.Options))
+	for _, option := range poll.Options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			return nil, &chirpValidationError{Message: "Poll options can't be empty"}
+		}
+		if utf8.RuneCountInString(option) > MAX_POLL_OPTION_LENGTH {
+			return nil, &chirpValidationError{
+				Message: fmt.Sprintf("Poll options must be at most %d characters", MAX_POLL_OPTION_LENGTH),
+			}
+		}
+		options = append(options, option)
+	}
+
+	duration := time.Until(poll.ExpiresAt)
+	if duration < MIN_POLL_DURATION || duration > MAX_POLL_DURATION {
+		return nil, &chirpValidationError{Message: fmt.Sprintf(
+			"Polls must run for %s to %s",
+			formatPollDuration(MIN_POLL_DURATION),
+			formatPollDuration(MAX_POLL_DURATION),
+		)}
+	}
+
+	return options, nil
+}
+
+// createPoll attaches poll to chirpID, see createChirp
+func createPoll(ctx context.Context, q *database.Queries, chirpID uuid.UUID, poll newPoll) error {
+	options, err := poll.validate()
+	if err != nil {
+		return err
+	}
+
+	createdPoll, err := q.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:   chirpID,
+		ExpiresAt: poll.ExpiresAt.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, option := range options {
+		if err := q.CreatePollOption(ctx, database.CreatePollOptionParams{
+			PollID:   createdPoll.ID,
+			Position: int32(i),
+			Text:     option,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type pollOptionJson struct {
+	Id    string `json:"id"`
+	Text  string `json:"text"`
+	Votes *int64 `json:"votes"`
+}
+
+// pollJson only carries vote counts once the poll is closed or the viewer
+// has voted, so early results can't sway anyone
+type pollJson struct {
+	Id            string           `json:"id"`
+	ExpiresAt     string           `json:"expires_at"`
+	Closed        bool             `json:"closed"`
+	VotedOptionID *string          `json:"voted_option_id"`
+	TotalVotes    *int64           `json:"total_votes"`
+	Options       []pollOptionJson `json:"options"`
+}
+
+// pollVersion is what a poll's results change with. Votes don't touch the
+// chirp, so they get their own validator.
+type pollVersion struct {
+	votes      int64
+	lastVoteAt time.Time
+	closed     bool
+}
+
+// pollVersions loads the versions of the polls of chirps, keyed by chirp
+func (cfg *ApiConfig) pollVersions(ctx context.Context, chirps []database.Chirp) (map[uuid.UUID]pollVersion, error) {
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	rows, err := cfg.DbQueries.GetPollVersions(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	versions := make(map[uuid.UUID]pollVersion, len(rows))
+	for _, row := range rows {
+		versions[row.ChirpID] = pollVersion{
+			votes:      row.Votes,
+			lastVoteAt: row.LastVoteAt,
+			closed:     !row.ExpiresAt.After(now),
+		}
+	}
+	return versions, nil
+}
+
+// chirpPolls loads the polls of chirpIDs as seen by viewerID, keyed by chirp
+func (cfg *ApiConfig) chirpPolls(ctx context.Context, viewerID uuid.UUID, chirpIDs []uuid.UUID) (map[uuid.UUID]*pollJson, error) {
+	polls, err := cfg.DbQueries.GetPollsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	pollsByChirp := map[uuid.UUID]*pollJson{}
+	if len(polls) == 0 {
+		return pollsByChirp, nil
+	}
+
+	pollIDs := make([]uuid.UUID, 0, len(polls))
+	for _, poll := range polls {
+		pollIDs = append(pollIDs, poll.ID)
+	}
+
+	results, err := cfg.DbQueries.GetPollResults(ctx, pollIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	votedOptions := map[uuid.UUID]uuid.UUID{}
+	if viewerID != uuid.Nil {
+		votes, err := cfg.DbQueries.GetUserPollVotes(ctx, database.GetUserPollVotesParams{
+			UserID:  viewerID,
+			PollIds: pollIDs,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, vote := range votes {
+			votedOptions[vote.PollID] = vote.OptionID
+		}
+	}
+
+	pollsByID := map[uuid.UUID]*pollJson{}
+	now := time.Now().UTC()
+	for _, poll := range polls {
+		pollResp := &pollJson{
+			Id:        poll.ID.String(),
+			ExpiresAt: poll.ExpiresAt.Format(time.RFC3339),
+			Closed:    !poll.ExpiresAt.After(now),
+			Options:   []pollOptionJson{},
+		}
+		if optionID, ok := votedOptions[poll.ID]; ok {
+			votedOptionID := optionID.String()
+			pollResp.VotedOptionID = &votedOptionID
+		}
+		if pollResp.Closed || pollResp.VotedOptionID != nil {
+			totalVotes := int64(0)
+			pollResp.TotalVotes = &totalVotes
+		}
+		pollsByID[poll.ID] = pollResp
+		pollsByChirp[poll.ChirpID] = pollResp
+	}
+
+	for _, result := range results {
+		pollResp := pollsByID[result.PollID]
+		option := pollOptionJson{
+			Id:   result.ID.String(),
+			Text: result.Text,
+		}
+		if pollResp.TotalVotes != nil {
+			votes := result.Votes
+			option.Votes = &votes
+			*pollResp.TotalVotes += votes
+		}
+		pollResp.Options = append(pollResp.Options, option)
+	}
+
+	return pollsByChirp, nil
+}
+
+func (cfg *ApiConfig) PollVoteHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		OptionID uuid.UUID `json:"option_id"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	chirp, err := cfg.DbQueries.GetVisibleChirp(
+		context.Background(),
+		database.GetVisibleChirpParams{
+			ID:       chirpID,
+			ViewerID: userID,
+		},
+	)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	blocked, err := cfg.isBlocked(context.Background(), userID, chirp.UserID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	poll, err := cfg.DbQueries.GetPollByChirp(context.Background(), chirp.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Chirp has no poll")
+		return
+	}
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !poll.ExpiresAt.After(time.Now().UTC()) {
+		respondWithError(w, http.StatusConflict, "Poll is closed")
+		return
+	}
+
+	if _, err := cfg.DbQueries.GetPollOption(context.Background(), database.GetPollOptionParams{
+		ID:     params.OptionID,
+		PollID: poll.ID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid poll option")
+		return
+	}
+
+	voted, err := cfg.DbQueries.CastPollVote(context.Background(), database.CastPollVoteParams{
+		PollID:   poll.ID,
+		UserID:   userID,
+		OptionID: params.OptionID,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if voted == 0 {
+		respondWithError(w, http.StatusConflict, "You have already voted")
+		return
+	}
+
+	// Votes leave the chirp alone, they change its ETag through the poll's
+	// own version and aren't broadcast
+	chirpResp, err := cfg.chirpJson(context.Background(), userID, chirp)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirpResp)
+}
+
+// RunPollCloser tells authors when their polls close, checking every
+// interval until ctx is done. Polls are claimed one at a time with
+// FOR UPDATE SKIP LOCKED, so replicas never notify twice.
+func (cfg *ApiConfig) RunPollCloser(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				poll, err := cfg.DbQueries.ClaimClosedPoll(ctx)
+				if errors.Is(err, sql.ErrNoRows) {
+					break
+				}
+				if err != nil {
+					log.Printf("poll closer: %v\n", err)
+					break
+				}
+
+				// Closing reveals the results, which changes the chirp
+				chirp, err := cfg.DbQueries.TouchChirp(ctx, poll.ChirpID)
+				if err != nil {
+					log.Printf("poll closer: %v\n", err)
+					continue
+				}
+				cfg.publishChirpEvent(ctx, events.KindChirpUpdated, chirp)
+				cfg.notify(ctx, chirp.UserID, uuid.Nil, NOTIFICATION_POLL_CLOSED, chirp.ID)
+			}
+		}
+	}
+}
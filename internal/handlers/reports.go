@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/dmitriy-zverev/chirpy/internal/events"
+	"github.com/google/uuid"
+)
+
+// reportReasons are the categories a chirp can be reported for
+var reportReasons = []string{
+	"spam",
+	"harassment",
+	"hate",
+	"violence",
+	"sexual",
+	"self_harm",
+	"misinformation",
+	"other",
+}
+
+func (cfg *ApiConfig) ReportPostHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if !slices.Contains(reportReasons, params.Reason) {
+		respondWithError(w, http.StatusBadRequest, "Reason must be one of "+strings.Join(reportReasons, ", "))
+		return
+	}
+	if utf8.RuneCountInString(params.Details) > MAX_REPORT_DETAILS {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Details must be at most %d characters", MAX_REPORT_DETAILS))
+		return
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	chirp, err := cfg.DbQueries.GetVisibleChirp(
+		context.Background(),
+		database.GetVisibleChirpParams{
+			ID:       chirpID,
+			ViewerID: userID,
+		},
+	)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if chirp.UserID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't report your own chirp")
+		return
+	}
+
+	// Reporting stays open to users the author blocked, that's often
+	// exactly who needs it
+	if _, err := cfg.DbQueries.CreateReport(context.Background(), database.CreateReportParams{
+		ReporterID: userID,
+		ChirpID:    chirp.ID,
+		Reason:     params.Reason,
+		Details:    strings.TrimSpace(params.Details),
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type reportJson struct {
+	Id         string `json:"id"`
+	CreatedAt  string `json:"created_at"`
+	ReporterID string `json:"reporter_id"`
+	Reason     string `json:"reason"`
+	Details    string `json:"details"`
+}
+
+type reportedChirpJson struct {
+	ChirpID     string         `json:"chirp_id"`
+	ChirpBody   string         `json:"chirp_body"`
+	ChirpUserID string         `json:"chirp_user_id"`
+	ReportCount int            `json:"report_count"`
+	Reasons     map[string]int `json:"reasons"`
+	Reports     []reportJson   `json:"reports"`
+}
+
+// ReportsGetHandler lists open reports grouped by chirp, chirps reported
+// first come first
+func (cfg *ApiConfig) ReportsGetHandler(w http.ResponseWriter, req *http.Request) {
+	if _, ok := cfg.authenticateModerator(w, req); !ok {
+		return
+	}
+
+	rows, err := cfg.DbQueries.GetOpenReports(context.Background())
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	queue := []*reportedChirpJson{}
+	byChirp := map[uuid.UUID]*reportedChirpJson{}
+	for _, row := range rows {
+		reported, ok := byChirp[row.ChirpID]
+		if !ok {
+			reported = &reportedChirpJson{
+				ChirpID:     row.ChirpID.String(),
+				ChirpBody:   row.ChirpBody,
+				ChirpUserID: row.ChirpUserID.String(),
+				Reasons:     map[string]int{},
+				Reports:     []reportJson{},
+			}
+			byChirp[row.ChirpID] = reported
+			queue = append(queue, reported)
+		}
+
+		reported.ReportCount++
+		reported.Reasons[row.Reason]++
+		reported.Reports = append(reported.Reports, reportJson{
+			Id:         row.ID.String(),
+			CreatedAt:  row.CreatedAt.String(),
+			ReporterID: row.ReporterID.String(),
+			Reason:     row.Reason,
+			Details:    row.Details,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, queue)
+}
+
+// ReportResolveHandler closes every open report of a chirp, dismissing
+// them, removing the chirp or suspending its author
+func (cfg *ApiConfig) ReportResolveHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Action string `json:"action"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	moderatorID, ok := cfg.authenticateModerator(w, req)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	chirp, err := cfg.DbQueries.GetChirp(context.Background(), chirpID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	status := REPORT_STATUS_ACTIONED
+	switch params.Action {
+	case REPORT_ACTION_DISMISS:
+		status = REPORT_STATUS_DISMISSED
+	case REPORT_ACTION_REMOVE:
+		if _, err := cfg.DbQueries.DeleteChirp(context.Background(), database.DeleteChirpParams{
+			ID:        chirp.ID,
+			DeletedBy: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		}); err != nil {
+			log.Printf("%v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err := cfg.DbQueries.TombstoneBookmarks(context.Background(), chirp.ID); err != nil {
+			log.Printf("%v\n", err)
+		}
+		cfg.publishChirpEvent(context.Background(), events.KindChirpDeleted, chirp)
+		cfg.enqueueChirpDeletedWebhook(context.Background(), chirp)
+	case REPORT_ACTION_SUSPEND:
+		if err := cfg.suspendUser(context.Background(), chirp.UserID); err != nil {
+			log.Printf("%v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	default:
+		respondWithError(w, http.StatusBadRequest, "Action must be dismiss, remove or suspend")
+		return
+	}
+
+	if _, err := cfg.DbQueries.ResolveReports(context.Background(), database.ResolveReportsParams{
+		ChirpID:    chirp.ID,
+		Status:     status,
+		ResolvedBy: uuid.NullUUID{UUID: moderatorID, Valid: true},
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
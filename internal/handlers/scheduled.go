@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/dmitriy-zverev/chirpy/internal/events"
+	"github.com/google/uuid"
+)
+
+type scheduledChirpJson struct {
+	Id        string  `json:"id"`
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+	Body      string  `json:"body"`
+	Status    string  `json:"status"`
+	PublishAt *string `json:"publish_at"`
+	Attempts  int32   `json:"attempts"`
+	LastError *string `json:"last_error"`
+	ChirpID   *string `json:"chirp_id"`
+}
+
+func newScheduledChirpJson(scheduled database.ScheduledChirp) scheduledChirpJson {
+	resp := scheduledChirpJson{
+		Id:        scheduled.ID.String(),
+		CreatedAt: scheduled.CreatedAt.String(),
+		UpdatedAt: scheduled.UpdatedAt.String(),
+		Body:      scheduled.Body,
+		Status:    scheduled.Status,
+		Attempts:  scheduled.Attempts,
+	}
+	if scheduled.PublishAt.Valid {
+		publishAt := scheduled.PublishAt.Time.Format(time.RFC3339)
+		resp.PublishAt = &publishAt
+	}
+	if scheduled.LastError.Valid {
+		resp.LastError = &scheduled.LastError.String
+	}
+	if scheduled.ChirpID.Valid {
+		chirpID := scheduled.ChirpID.UUID.String()
+		resp.ChirpID = &chirpID
+	}
+	return resp
+}
+
+type scheduledChirpParameters struct {
+	Body      string     `json:"body"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+// statusAndPublishAt turns the request into a draft when publish_at is
+// omitted and a scheduled chirp otherwise
+func (params scheduledChirpParameters) statusAndPublishAt() (string, sql.NullTime, error) {
+	if params.PublishAt == nil {
+		return SCHEDULED_STATUS_DRAFT, sql.NullTime{}, nil
+	}
+
+	publishAt := params.PublishAt.UTC()
+	if publishAt.Before(time.Now().UTC()) {
+		return "", sql.NullTime{}, errors.New("publish_at must be in the future")
+	}
+
+	return SCHEDULED_STATUS_SCHEDULED, sql.NullTime{Time: publishAt, Valid: true}, nil
+}
+
+func (cfg *ApiConfig) ScheduledChirpsPostHandler(w http.ResponseWriter, req *http.Request) {
+	params := scheduledChirpParameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if err := cfg.validateChirp(context.Background(), userID, params.Body); err != nil {
+		var validationErr *chirpValidationError
+		if errors.As(err, &validationErr) {
+			respondWithChirpValidationError(w, http.StatusBadRequest, validationErr)
+			return
+		}
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	status, publishAt, err := params.statusAndPublishAt()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	scheduled, err := cfg.DbQueries.CreateScheduledChirp(context.Background(), database.CreateScheduledChirpParams{
+		UserID:    userID,
+		Body:      params.Body,
+		Status:    status,
+		PublishAt: publishAt,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// Published since it was checked above
+		respondWithError(w, http.StatusConflict, "Chirp is already published")
+		return
+	}
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newScheduledChirpJson(scheduled))
+}
+
+func (cfg *ApiConfig) ScheduledChirpsGetHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	scheduledChirps, err := cfg.DbQueries.GetScheduledChirps(context.Background(), userID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	scheduledJsons := []scheduledChirpJson{}
+	for _, scheduled := range scheduledChirps {
+		scheduledJsons = append(scheduledJsons, newScheduledChirpJson(scheduled))
+	}
+
+	respondWithJSON(w, http.StatusOK, scheduledJsons)
+}
+
+// ownScheduledChirp authenticates the caller and resolves the
+// {scheduledID} path value to one of the caller's drafts or scheduled chirps
+func (cfg *ApiConfig) ownScheduledChirp(w http.ResponseWriter, req *http.Request) (database.ScheduledChirp, bool) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return database.ScheduledChirp{}, false
+	}
+
+	scheduledID, err := uuid.Parse(req.PathValue("scheduledID"))
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return database.ScheduledChirp{}, false
+	}
+
+	scheduled, err := cfg.DbQueries.GetScheduledChirp(context.Background(), scheduledID)
+	if err != nil || scheduled.UserID != userID {
+		w.WriteHeader(http.StatusNotFound)
+		return database.ScheduledChirp{}, false
+	}
+
+	return scheduled, true
+}
+
+func (cfg *ApiConfig) ScheduledChirpPutHandler(w http.ResponseWriter, req *http.Request) {
+	params := scheduledChirpParameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	scheduled, ok := cfg.ownScheduledChirp(w, req)
+	if !ok {
+		return
+	}
+
+	if scheduled.Status == SCHEDULED_STATUS_PUBLISHED {
+		respondWithError(w, http.StatusConflict, "Chirp is already published")
+		return
+	}
+
+	if err := cfg.validateChirp(context.Background(), scheduled.UserID, params.Body); err != nil {
+		var validationErr *chirpValidationError
+		if errors.As(err, &validationErr) {
+			respondWithChirpValidationError(w, http.StatusBadRequest, validationErr)
+			return
+		}
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	status, publishAt, err := params.statusAndPublishAt()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	scheduled, err = cfg.DbQueries.UpdateScheduledChirp(context.Background(), database.UpdateScheduledChirpParams{
+		ID:        scheduled.ID,
+		Body:      params.Body,
+		Status:    status,
+		PublishAt: publishAt,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newScheduledChirpJson(scheduled))
+}
+
+func (cfg *ApiConfig) ScheduledChirpDeleteHandler(w http.ResponseWriter, req *http.Request) {
+	scheduled, ok := cfg.ownScheduledChirp(w, req)
+	if !ok {
+		return
+	}
+
+	deleted, err := cfg.DbQueries.DeleteScheduledChirp(context.Background(), scheduled.ID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusConflict, "Chirp is already published")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunScheduler publishes due scheduled chirps every interval until ctx is
+// done. Rows are claimed with FOR UPDATE SKIP LOCKED, so any number of
+// replicas can run it side by side without publishing a chirp twice.
+func (cfg *ApiConfig) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				claimed, err := cfg.publishNextScheduledChirp(ctx)
+				if err != nil {
+					log.Printf("scheduler: %v\n", err)
+					break
+				}
+				if !claimed {
+					break
+				}
+			}
+		}
+	}
+}
+
+// publishNextScheduledChirp publishes one due chirp through createChirp,
+// recording a failure for retry instead when that doesn't work. It
+// returns false once nothing is due.
+func (cfg *ApiConfig) publishNextScheduledChirp(ctx context.Context) (bool, error) {
+	tx, err := cfg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.DbQueries.WithTx(tx)
+
+	scheduled, err := qtx.ClaimDueScheduledChirp(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Chirps of suspended users fail instead of going out, their owner can
+	// schedule them again once the suspension is lifted
+	owner, err := qtx.GetUser(ctx, scheduled.UserID)
+	if err != nil {
+		return false, err
+	}
+	if owner.SuspendedAt.Valid {
+		if err := recordScheduleFailure(ctx, qtx, scheduled, errAccountSuspended); err != nil {
+			return false, err
+		}
+		return true, tx.Commit()
+	}
+
+	// A failed insert aborts the transaction; the savepoint lets us still
+	// record the failure while holding the row lock
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT publish"); err != nil {
+		return false, err
+	}
+
+	chirp, publishErr := cfg.createChirp(ctx, qtx, scheduled.UserID, newChirp{Body: scheduled.Body})
+
+	// A held chirp is out of the scheduler's hands, it is published
+	// without a chirp ID and appears once a moderator approves it
+	var heldErr *chirpHeldError
+	if errors.As(publishErr, &heldErr) {
+		publishErr = nil
+	}
+
+	if publishErr != nil {
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT publish"); err != nil {
+			return false, err
+		}
+		if err := recordScheduleFailure(ctx, qtx, scheduled, publishErr); err != nil {
+			return false, err
+		}
+		return true, tx.Commit()
+	}
+
+	if err := qtx.MarkScheduledChirpPublished(ctx, database.MarkScheduledChirpPublishedParams{
+		ID:      scheduled.ID,
+		ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: heldErr == nil},
+	}); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	if heldErr == nil {
+		cfg.Fanout.Enqueue(chirp)
+		cfg.publishChirpEvent(ctx, events.KindChirpCreated, chirp)
+	}
+	return true, nil
+}
+
+// recordScheduleFailure retries with a quadratic backoff, giving up after
+// MAX_SCHEDULE_ATTEMPTS. Chirps failing validation or belonging to a
+// suspended user are never retried.
+func recordScheduleFailure(ctx context.Context, q *database.Queries, scheduled database.ScheduledChirp, publishErr error) error {
+	log.Printf("scheduler: cannot publish %s: %v\n", scheduled.ID, publishErr)
+
+	attempts := scheduled.Attempts + 1
+	status := SCHEDULED_STATUS_SCHEDULED
+	publishAt := sql.NullTime{
+		Time:  time.Now().UTC().Add(time.Duration(attempts*attempts) * time.Minute),
+		Valid: true,
+	}
+
+	var validationErr *chirpValidationError
+	if errors.As(publishErr, &validationErr) || errors.Is(publishErr, errAccountSuspended) || attempts >= MAX_SCHEDULE_ATTEMPTS {
+		status = SCHEDULED_STATUS_FAILED
+		publishAt = scheduled.PublishAt
+	}
+
+	return q.RecordScheduledChirpFailure(ctx, database.RecordScheduledChirpFailureParams{
+		ID:        scheduled.ID,
+		Status:    status,
+		PublishAt: publishAt,
+		LastError: sql.NullString{String: publishErr.Error(), Valid: true},
+	})
+}
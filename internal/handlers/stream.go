@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/chirptext"
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/dmitriy-zverev/chirpy/internal/events"
+	"github.com/google/uuid"
+)
+
+// publishChirpEvent tells stream subscribers on every replica about a
+// change to chirp. The change itself has already happened, so failing to
+// publish it is only logged.
+func (cfg *ApiConfig) publishChirpEvent(ctx context.Context, kind string, chirp database.Chirp) {
+	if err := cfg.Events.Publish(ctx, events.Event{
+		Kind:     kind,
+		ChirpID:  chirp.ID,
+		AuthorID: chirp.UserID,
+		Hashtags: chirptext.Hashtags(chirp.Body),
+	}); err != nil {
+		log.Printf("%v\n", err)
+	}
+}
+
+type streamFilter struct {
+	authorID uuid.UUID
+	hashtags []string
+}
+
+func (f streamFilter) matches(event events.Event) bool {
+	if !event.IsChirpEvent() {
+		return false
+	}
+	if f.authorID != uuid.Nil && event.AuthorID != f.authorID {
+		return false
+	}
+	if len(f.hashtags) == 0 {
+		return true
+	}
+	for _, hashtag := range f.hashtags {
+		if slices.Contains(event.Hashtags, hashtag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfg *ApiConfig) ChirpsStreamHandler(w http.ResponseWriter, req *http.Request) {
+	viewerID, err := cfg.optionalAuthenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	filter := streamFilter{}
+	if authorIDString := req.URL.Query().Get("author_id"); authorIDString != "" {
+		filter.authorID, err = uuid.Parse(authorIDString)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author_id")
+			return
+		}
+	}
+	for _, hashtag := range req.URL.Query()["hashtag"] {
+		hashtag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(hashtag), "#"))
+		if hashtag == "" {
+			respondWithError(w, http.StatusBadRequest, "Invalid hashtag")
+			return
+		}
+		filter.hashtags = append(filter.hashtags, hashtag)
+	}
+	if len(filter.hashtags) > MAX_STREAM_HASHTAGS {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("At most %d hashtags can be followed", MAX_STREAM_HASHTAGS))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Printf("streaming is not supported by %T\n", w)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Streams outlive the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("%v\n", err)
+	}
+
+	sub, backlog, resumed := cfg.Events.Subscribe(req.Header.Get("Last-Event-ID"))
+	defer cfg.Events.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// The events missed since Last-Event-ID are gone, the client has to
+	// reload before relying on the stream again
+	if !resumed {
+		if _, err := io.WriteString(w, "event: reset\ndata: {}\n\n"); err != nil {
+			return
+		}
+	}
+	for _, event := range backlog {
+		if err := cfg.writeStreamEvent(req.Context(), w, viewerID, filter, event); err != nil {
+			log.Printf("%v\n", err)
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(STREAM_HEARTBEAT_INTERVAL)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case event, open := <-sub.C:
+			// Dropped for falling behind, the client reconnects and resumes
+			// with Last-Event-ID
+			if !open {
+				return
+			}
+			if event.Suspends(viewerID) {
+				return
+			}
+			if err := cfg.writeStreamEvent(req.Context(), w, viewerID, filter, event); err != nil {
+				log.Printf("%v\n", err)
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeStreamEvent sends event if it passes filter and the viewer may see
+// its chirp
+func (cfg *ApiConfig) writeStreamEvent(ctx context.Context, w io.Writer, viewerID uuid.UUID, filter streamFilter, event events.Event) error {
+	if !filter.matches(event) {
+		return nil
+	}
+
+	data, ok, err := cfg.chirpEventData(ctx, viewerID, event)
+	if err != nil || !ok {
+		return err
+	}
+
+	dat, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Kind, dat)
+	return err
+}
+
+// chirpEventData renders a chirp event for viewerID, ok is false when they
+// may not see the chirp. Created and updated chirps are rendered the way
+// listings render them, deleted ones only by ID.
+func (cfg *ApiConfig) chirpEventData(ctx context.Context, viewerID uuid.UUID, event events.Event) (data any, ok bool, err error) {
+	visible, err := cfg.DbQueries.IsChirpVisible(ctx, database.IsChirpVisibleParams{
+		ID:       event.ChirpID,
+		ViewerID: viewerID,
+	})
+	if err != nil || !visible {
+		return nil, false, err
+	}
+
+	if event.Kind == events.KindChirpDeleted {
+		return struct {
+			Id string `json:"id"`
+		}{Id: event.ChirpID.String()}, true, nil
+	}
+
+	chirp, err := cfg.DbQueries.GetChirp(ctx, event.ChirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Deleted since, its own event follows
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	chirpsJsons, err := cfg.chirpJsons(ctx, viewerID, []database.Chirp{chirp})
+	if err != nil {
+		return nil, false, err
+	}
+	return chirpsJsons[0], true, nil
+}
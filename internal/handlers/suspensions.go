@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/dmitriy-zverev/chirpy/internal/auth"
+	"github.com/dmitriy-zverev/chirpy/internal/events"
+	"github.com/google/uuid"
+)
+
+var errAccountSuspended = errors.New("account is suspended")
+
+// MiddlewareSuspension rejects every request bearing the JWT of a
+// suspended user, so no authenticated handler has to check on its own.
+// Requests without a valid JWT pass through for the handler to deal with.
+func (cfg *ApiConfig) MiddlewareSuspension(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token, err := auth.GetBearerToken(req.Header)
+		if err != nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		userID, err := auth.ValidateJWT(token, string(cfg.JWTSecret))
+		if err != nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		user, err := cfg.DbQueries.GetUser(req.Context(), userID)
+		if err != nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		if user.SuspendedAt.Valid {
+			respondWithError(w, http.StatusForbidden, "Your account is suspended")
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// suspendUser suspends userID and revokes their refresh tokens. JWTs they
+// already hold are turned away by MiddlewareSuspension, and the streams
+// and WebSockets they have open are closed on every replica.
+func (cfg *ApiConfig) suspendUser(ctx context.Context, userID uuid.UUID) error {
+	if err := cfg.DbQueries.SuspendUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := cfg.DbQueries.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return err
+	}
+
+	// The suspension has already happened, failing to publish it is only
+	// logged
+	if err := cfg.Events.Publish(ctx, events.Event{
+		Kind:   events.KindUserSuspended,
+		UserID: userID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+	}
+	return nil
+}
+
+func (cfg *ApiConfig) SuspensionPutHandler(w http.ResponseWriter, req *http.Request) {
+	if _, ok := cfg.authenticateModerator(w, req); !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if _, err := cfg.DbQueries.GetUser(context.Background(), userID); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if err := cfg.suspendUser(context.Background(), userID); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *ApiConfig) SuspensionDeleteHandler(w http.ResponseWriter, req *http.Request) {
+	if _, ok := cfg.authenticateModerator(w, req); !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	unsuspended, err := cfg.DbQueries.UnsuspendUser(context.Background(), userID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if unsuspended == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/dmitriy-zverev/chirpy/internal/events"
+	"github.com/google/uuid"
+)
+
+// ChirpsTrashGetHandler lists the caller's deleted chirps that can still be
+// restored, most recently deleted first. Chirps removed by moderators
+// don't show up here.
+func (cfg *ApiConfig) ChirpsTrashGetHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	chirps, err := cfg.DbQueries.GetTrashedChirps(context.Background(), database.GetTrashedChirpsParams{
+		UserID:       userID,
+		DeletedAfter: time.Now().UTC().Add(-CHIRP_TRASH_RETENTION),
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	type trashedChirpJson struct {
+		chirpJson
+		DeletedAt string `json:"deleted_at"`
+		PurgeAt   string `json:"purge_at"`
+	}
+
+	chirpsJsons, err := cfg.chirpJsons(context.Background(), userID, chirps)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	trashed := []trashedChirpJson{}
+	for i, chirp := range chirps {
+		trashed = append(trashed, trashedChirpJson{
+			chirpJson: chirpsJsons[i],
+			DeletedAt: chirp.DeletedAt.Time.Format(time.RFC3339),
+			PurgeAt:   chirp.DeletedAt.Time.Add(CHIRP_TRASH_RETENTION).Format(time.RFC3339),
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, trashed)
+}
+
+func (cfg *ApiConfig) ChirpRestoreHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	chirp, err := cfg.DbQueries.RestoreChirp(context.Background(), database.RestoreChirpParams{
+		ID:           chirpID,
+		UserID:       userID,
+		DeletedAfter: time.Now().UTC().Add(-CHIRP_TRASH_RETENTION),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Chirp is not in your trash")
+		return
+	}
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := cfg.DbQueries.RestoreBookmarks(context.Background(), chirp.ID); err != nil {
+		log.Printf("%v\n", err)
+	}
+	cfg.publishChirpEvent(context.Background(), events.KindChirpCreated, chirp)
+	if err := enqueueWebhook(context.Background(), cfg.DbQueries, WEBHOOK_EVENT_CHIRP_CREATED, chirp.UserID, newWebhookChirpJson(chirp)); err != nil {
+		log.Printf("%v\n", err)
+	}
+
+	chirpResp, err := cfg.chirpJson(context.Background(), userID, chirp)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, chirpResp)
+}
+
+// RunTrashPurger hard-deletes chirps that have been deleted for longer
+// than CHIRP_TRASH_RETENTION, checking every interval until ctx is done
+func (cfg *ApiConfig) RunTrashPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := cfg.DbQueries.PurgeDeletedChirps(ctx, time.Now().UTC().Add(-CHIRP_TRASH_RETENTION))
+			if err != nil {
+				log.Printf("trash purger: %v\n", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("trash purger: purged %d chirps\n", purged)
+			}
+		}
+	}
+}
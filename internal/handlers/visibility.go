@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// validateVisibility checks a new chirp's visibility and mentions. An
+// empty visibility means public.
+func validateVisibility(visibility string, mentions []uuid.UUID) error {
+	switch visibility {
+	case "", CHIRP_VISIBILITY_PUBLIC, CHIRP_VISIBILITY_FOLLOWERS, CHIRP_VISIBILITY_MENTIONED:
+	default:
+		return &chirpValidationError{
+			Message: fmt.Sprintf(
+				"Visibility must be %s, %s or %s",
+				CHIRP_VISIBILITY_PUBLIC,
+				CHIRP_VISIBILITY_FOLLOWERS,
+				CHIRP_VISIBILITY_MENTIONED,
+			),
+		}
+	}
+
+	if len(mentions) > MAX_CHIRP_MENTIONS {
+		return &chirpValidationError{
+			Message: fmt.Sprintf("A chirp can mention at most %d users", MAX_CHIRP_MENTIONS),
+		}
+	}
+
+	return nil
+}
+
+// chirpMentions loads the users each chirp mentions, keyed by chirp ID
+func (cfg *ApiConfig) chirpMentions(ctx context.Context, chirpIDs []uuid.UUID) (map[uuid.UUID][]string, error) {
+	mentions, err := cfg.DbQueries.GetMentionsForChirps(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	mentionsByChirp := map[uuid.UUID][]string{}
+	for _, mention := range mentions {
+		mentionsByChirp[mention.ChirpID] = append(mentionsByChirp[mention.ChirpID], mention.UserID.String())
+	}
+	return mentionsByChirp, nil
+}
@@ -0,0 +1,491 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/dmitriy-zverev/chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+var webhookEvents = []string{
+	WEBHOOK_EVENT_CHIRP_CREATED,
+	WEBHOOK_EVENT_CHIRP_DELETED,
+	WEBHOOK_EVENT_USER_UPGRADED,
+}
+
+// webhookEnvelope is the body of every delivery. Its ID is the same for
+// all subscriptions an event goes to, and across retries.
+type webhookEnvelope struct {
+	Id        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type webhookChirpJson struct {
+	Id             string    `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UserID         string    `json:"user_id"`
+	Body           string    `json:"body"`
+	ContentWarning string    `json:"content_warning"`
+	Sensitive      bool      `json:"sensitive"`
+	Visibility     string    `json:"visibility"`
+}
+
+func newWebhookChirpJson(chirp database.Chirp) webhookChirpJson {
+	return webhookChirpJson{
+		Id:             chirp.ID.String(),
+		CreatedAt:      chirp.CreatedAt,
+		UserID:         chirp.UserID.String(),
+		Body:           chirp.Body,
+		ContentWarning: chirp.ContentWarning,
+		Sensitive:      chirp.Sensitive,
+		Visibility:     chirp.Visibility,
+	}
+}
+
+// enqueueWebhook queues deliveries of event to every subscription that
+// wants it: admins' subscriptions get every event, users' only those about
+// subjectID, themselves. Given a transaction's queries the deliveries are
+// only queued if it commits.
+func enqueueWebhook(ctx context.Context, q *database.Queries, event string, subjectID uuid.UUID, data any) error {
+	eventID := uuid.New()
+	payload, err := json.Marshal(webhookEnvelope{
+		Id:        eventID.String(),
+		Type:      event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = q.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventID:   eventID,
+		Event:     event,
+		Payload:   string(payload),
+		SubjectID: subjectID,
+	})
+	return err
+}
+
+// enqueueChirpDeletedWebhook is for deletions that already happened, a
+// failure to queue is only logged
+func (cfg *ApiConfig) enqueueChirpDeletedWebhook(ctx context.Context, chirp database.Chirp) {
+	if err := enqueueWebhook(ctx, cfg.DbQueries, WEBHOOK_EVENT_CHIRP_DELETED, chirp.UserID, struct {
+		Id     string `json:"id"`
+		UserID string `json:"user_id"`
+	}{
+		Id:     chirp.ID.String(),
+		UserID: chirp.UserID.String(),
+	}); err != nil {
+		log.Printf("%v\n", err)
+	}
+}
+
+type webhookJson struct {
+	Id        string   `json:"id"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+	Url       string   `json:"url"`
+	Events    []string `json:"events"`
+	Secret    string   `json:"secret,omitempty"`
+}
+
+func newWebhookJson(subscription database.WebhookSubscription) webhookJson {
+	return webhookJson{
+		Id:        subscription.ID.String(),
+		CreatedAt: subscription.CreatedAt.String(),
+		UpdatedAt: subscription.UpdatedAt.String(),
+		Url:       subscription.Url,
+		Events:    subscription.Events,
+	}
+}
+
+func validateWebhookUrl(rawUrl string) error {
+	if len(rawUrl) > MAX_WEBHOOK_URL_LENGTH {
+		return fmt.Errorf("url is longer than %d characters", MAX_WEBHOOK_URL_LENGTH)
+	}
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if parsedUrl.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+	return nil
+}
+
+func (cfg *ApiConfig) WebhooksPostHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Url    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if err := validateWebhookUrl(params.Url); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(params.Events) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one event is required")
+		return
+	}
+	for _, event := range params.Events {
+		if !slices.Contains(webhookEvents, event) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown event %s", event))
+			return
+		}
+	}
+	slices.Sort(params.Events)
+	params.Events = slices.Compact(params.Events)
+
+	count, err := cfg.DbQueries.CountWebhookSubscriptions(context.Background(), userID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if count >= MAX_WEBHOOK_SUBSCRIPTIONS {
+		respondWithError(w, http.StatusConflict, fmt.Sprintf("You can have at most %d webhooks", MAX_WEBHOOK_SUBSCRIPTIONS))
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	subscription, err := cfg.DbQueries.CreateWebhookSubscription(context.Background(), database.CreateWebhookSubscriptionParams{
+		UserID: userID,
+		Url:    params.Url,
+		Secret: secret,
+		Events: params.Events,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// The secret is only ever shown here
+	resp := newWebhookJson(subscription)
+	resp.Secret = subscription.Secret
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *ApiConfig) WebhooksGetHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	subscriptions, err := cfg.DbQueries.GetWebhookSubscriptions(context.Background(), userID)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	webhookJsons := []webhookJson{}
+	for _, subscription := range subscriptions {
+		webhookJsons = append(webhookJsons, newWebhookJson(subscription))
+	}
+
+	respondWithJSON(w, http.StatusOK, webhookJsons)
+}
+
+func (cfg *ApiConfig) WebhookDeleteHandler(w http.ResponseWriter, req *http.Request) {
+	subscription, ok := cfg.ownWebhook(w, req)
+	if !ok {
+		return
+	}
+
+	if _, err := cfg.DbQueries.DeleteWebhookSubscription(context.Background(), database.DeleteWebhookSubscriptionParams{
+		ID:     subscription.ID,
+		UserID: subscription.UserID,
+	}); err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownWebhook authenticates the caller and resolves the {webhookID} path
+// value to one of the caller's subscriptions
+func (cfg *ApiConfig) ownWebhook(w http.ResponseWriter, req *http.Request) (database.WebhookSubscription, bool) {
+	userID, err := cfg.authenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return database.WebhookSubscription{}, false
+	}
+
+	webhookID, err := uuid.Parse(req.PathValue("webhookID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return database.WebhookSubscription{}, false
+	}
+
+	subscription, err := cfg.DbQueries.GetWebhookSubscription(context.Background(), webhookID)
+	if err != nil || subscription.UserID != userID {
+		w.WriteHeader(http.StatusNotFound)
+		return database.WebhookSubscription{}, false
+	}
+
+	return subscription, true
+}
+
+type webhookDeliveryJson struct {
+	Id             string          `json:"id"`
+	CreatedAt      string          `json:"created_at"`
+	EventID        string          `json:"event_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *string         `json:"next_attempt_at"`
+	LastAttemptAt  *string         `json:"last_attempt_at"`
+	LastStatusCode *int32          `json:"last_status_code"`
+	LastError      *string         `json:"last_error"`
+	DeliveredAt    *string         `json:"delivered_at"`
+}
+
+func newWebhookDeliveryJson(delivery database.WebhookDelivery) webhookDeliveryJson {
+	resp := webhookDeliveryJson{
+		Id:        delivery.ID.String(),
+		CreatedAt: delivery.CreatedAt.Format(time.RFC3339Nano),
+		EventID:   delivery.EventID.String(),
+		Event:     delivery.Event,
+		Payload:   json.RawMessage(delivery.Payload),
+		Status:    delivery.Status,
+		Attempts:  delivery.Attempts,
+	}
+	if delivery.Status == WEBHOOK_DELIVERY_PENDING {
+		nextAttemptAt := delivery.NextAttemptAt.Format(time.RFC3339)
+		resp.NextAttemptAt = &nextAttemptAt
+	}
+	if delivery.LastAttemptAt.Valid {
+		lastAttemptAt := delivery.LastAttemptAt.Time.Format(time.RFC3339)
+		resp.LastAttemptAt = &lastAttemptAt
+	}
+	if delivery.LastStatusCode.Valid {
+		resp.LastStatusCode = &delivery.LastStatusCode.Int32
+	}
+	if delivery.LastError.Valid {
+		resp.LastError = &delivery.LastError.String
+	}
+	if delivery.DeliveredAt.Valid {
+		deliveredAt := delivery.DeliveredAt.Time.Format(time.RFC3339)
+		resp.DeliveredAt = &deliveredAt
+	}
+	return resp
+}
+
+// WebhookDeliveriesGetHandler is the delivery log, newest first.
+// status=dead lists the dead letters.
+func (cfg *ApiConfig) WebhookDeliveriesGetHandler(w http.ResponseWriter, req *http.Request) {
+	subscription, ok := cfg.ownWebhook(w, req)
+	if !ok {
+		return
+	}
+
+	status := req.URL.Query().Get("status")
+	if status != "" && status != WEBHOOK_DELIVERY_PENDING && status != WEBHOOK_DELIVERY_DELIVERED && status != WEBHOOK_DELIVERY_DEAD {
+		respondWithError(w, http.StatusBadRequest, "Invalid status")
+		return
+	}
+
+	before, limit, err := parsePage(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deliveries, err := cfg.DbQueries.GetWebhookDeliveries(context.Background(), database.GetWebhookDeliveriesParams{
+		SubscriptionID: subscription.ID,
+		Status:         status,
+		Before:         before,
+		MaxResults:     limit,
+	})
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	deliveryJsons := []webhookDeliveryJson{}
+	for _, delivery := range deliveries {
+		deliveryJsons = append(deliveryJsons, newWebhookDeliveryJson(delivery))
+	}
+
+	respondWithJSON(w, http.StatusOK, deliveryJsons)
+}
+
+// WebhookDeliveryRetryHandler moves a dead letter back into the queue,
+// with a fresh set of attempts
+func (cfg *ApiConfig) WebhookDeliveryRetryHandler(w http.ResponseWriter, req *http.Request) {
+	subscription, ok := cfg.ownWebhook(w, req)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := uuid.Parse(req.PathValue("deliveryID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	delivery, err := cfg.DbQueries.RetryWebhookDelivery(context.Background(), database.RetryWebhookDeliveryParams{
+		ID:             deliveryID,
+		SubscriptionID: subscription.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "No dead delivery with this ID")
+		return
+	}
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newWebhookDeliveryJson(delivery))
+}
+
+// RunWebhookDispatcher sends due deliveries every interval until ctx is
+// done. A delivery is leased rather than locked while it is being sent:
+// if the replica sending it dies, the lease runs out and the delivery is
+// sent again, so queued deliveries survive restarts. Receivers should
+// deduplicate on the event ID.
+func (cfg *ApiConfig) RunWebhookDispatcher(ctx context.Context, interval time.Duration) {
+	publicClient := webhooks.NewClient(WEBHOOK_TIMEOUT, false)
+	// Admins' subscriptions are trusted to reach internal systems
+	internalClient := webhooks.NewClient(WEBHOOK_TIMEOUT, true)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				claimed, err := cfg.dispatchNextWebhook(ctx, publicClient, internalClient)
+				if err != nil {
+					log.Printf("webhook dispatcher: %v\n", err)
+					break
+				}
+				if !claimed {
+					break
+				}
+			}
+		}
+	}
+}
+
+// dispatchNextWebhook sends one due delivery, scheduling a retry with
+// exponential backoff when it fails. After MAX_WEBHOOK_ATTEMPTS it becomes
+// a dead letter. It returns false once nothing is due.
+func (cfg *ApiConfig) dispatchNextWebhook(ctx context.Context, publicClient, internalClient *http.Client) (bool, error) {
+	delivery, err := cfg.DbQueries.ClaimWebhookDelivery(ctx, time.Now().UTC().Add(2*WEBHOOK_TIMEOUT))
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	subscription, err := cfg.DbQueries.GetWebhookSubscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		return true, err
+	}
+	owner, err := cfg.DbQueries.GetUser(ctx, subscription.UserID)
+	if err != nil {
+		return true, err
+	}
+
+	client := publicClient
+	if owner.IsAdmin {
+		client = internalClient
+	}
+
+	statusCode, deliverErr := webhooks.Deliver(ctx, client, subscription.Url, subscription.Secret, delivery.EventID.String(), delivery.Event, []byte(delivery.Payload))
+	lastStatusCode := sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0}
+	if deliverErr == nil {
+		return true, cfg.DbQueries.MarkWebhookDelivered(ctx, database.MarkWebhookDeliveredParams{
+			ID:             delivery.ID,
+			LastStatusCode: lastStatusCode,
+		})
+	}
+
+	status := WEBHOOK_DELIVERY_PENDING
+	if delivery.Attempts >= MAX_WEBHOOK_ATTEMPTS {
+		status = WEBHOOK_DELIVERY_DEAD
+	}
+	lastError := deliverErr.Error()
+	lastError = lastError[:min(len(lastError), MAX_WEBHOOK_ERROR_LENGTH)]
+
+	return true, cfg.DbQueries.RecordWebhookFailure(ctx, database.RecordWebhookFailureParams{
+		ID:             delivery.ID,
+		Status:         status,
+		NextAttemptAt:  time.Now().UTC().Add(webhooks.Backoff(int(delivery.Attempts))),
+		LastStatusCode: lastStatusCode,
+		LastError:      sql.NullString{String: lastError, Valid: true},
+	})
+}
+
+// RunWebhookDeliveryPurger deletes successful deliveries older than
+// WEBHOOK_DELIVERY_RETENTION from the log, checking every interval until
+// ctx is done. Dead letters are kept until their subscription is deleted.
+func (cfg *ApiConfig) RunWebhookDeliveryPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := cfg.DbQueries.PurgeWebhookDeliveries(ctx, time.Now().UTC().Add(-WEBHOOK_DELIVERY_RETENTION))
+			if err != nil {
+				log.Printf("webhook delivery purger: %v\n", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("webhook delivery purger: purged %d deliveries\n", purged)
+			}
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/auth"
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/dmitriy-zverev/chirpy/internal/events"
+	"github.com/dmitriy-zverev/chirpy/internal/websocket"
+	"github.com/google/uuid"
+)
+
+// wsMessage is what both sides send over a WebSocket, one JSON object per
+// text message. Events are sent with their kind as the type.
+type wsMessage struct {
+	Type    string `json:"type"`
+	Token   string `json:"token,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// wsClient is one authenticated WebSocket connection. Its read loop
+// handles subscriptions, its event loop picks the events for its channels
+// and its write loop sends them along with heartbeat pings.
+type wsClient struct {
+	cfg    *ApiConfig
+	conn   *websocket.Conn
+	userID uuid.UUID
+	send   chan []byte
+
+	mu       sync.Mutex
+	channels map[string]bool
+}
+
+func (cfg *ApiConfig) WebSocketHandler(w http.ResponseWriter, req *http.Request) {
+	// Browsers can't set headers on WebSocket handshakes, those clients
+	// authenticate with their first message instead
+	userID, err := cfg.optionalAuthenticate(req)
+	if err != nil {
+		log.Printf("%v\n", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, req, WS_MAX_MESSAGE_SIZE, WS_IDLE_TIMEOUT)
+	if err != nil {
+		log.Printf("%v\n", err)
+		return
+	}
+	defer conn.Close(websocket.CloseNormal, "")
+
+	if userID == uuid.Nil {
+		userID, err = cfg.wsAuthenticate(conn)
+		if err != nil {
+			log.Printf("%v\n", err)
+			conn.Close(websocket.ClosePolicyViolation, "Authentication failed")
+			return
+		}
+	}
+
+	client := &wsClient{
+		cfg:      cfg,
+		conn:     conn,
+		userID:   userID,
+		send:     make(chan []byte, WS_SEND_QUEUE_SIZE),
+		channels: map[string]bool{},
+	}
+
+	sub, _, _ := cfg.Events.Subscribe("")
+	defer cfg.Events.Unsubscribe(sub)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go client.writeLoop(ctx)
+	go client.eventLoop(ctx, sub)
+
+	client.enqueue(wsMessage{Type: WS_MESSAGE_READY})
+	client.readLoop(ctx)
+}
+
+// wsAuthenticate expects an auth message carrying an access token. The
+// token never went through MiddlewareSuspension, so suspended users are
+// turned away here.
+func (cfg *ApiConfig) wsAuthenticate(conn *websocket.Conn) (uuid.UUID, error) {
+	dat, err := conn.ReadMessage()
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	message := wsMessage{}
+	if err := json.Unmarshal(dat, &message); err != nil || message.Type != WS_MESSAGE_AUTH {
+		return uuid.Nil, errors.New("expected an auth message")
+	}
+
+	userID, err := auth.ValidateJWT(message.Token, string(cfg.JWTSecret))
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	user, err := cfg.DbQueries.GetUser(context.Background(), userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if user.SuspendedAt.Valid {
+		return uuid.Nil, errAccountSuspended
+	}
+
+	return userID, nil
+}
+
+// enqueue hands message to the write loop. A client that can't keep up is
+// disconnected rather than allowed to hold up its event loop, and with it
+// the hub.
+func (c *wsClient) enqueue(message wsMessage) {
+	dat, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("%v\n", err)
+		return
+	}
+
+	select {
+	case c.send <- dat:
+	default:
+		c.conn.Close(websocket.CloseTryAgainLater, "Client too slow")
+	}
+}
+
+func (c *wsClient) enqueueError(channel, msg string) {
+	c.enqueue(wsMessage{Type: WS_MESSAGE_ERROR, Channel: channel, Error: msg})
+}
+
+func (c *wsClient) subscribed(channel string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.channels[channel]
+}
+
+// readLoop handles the client's messages until the connection closes or
+// fails, which includes the client missing its pongs
+func (c *wsClient) readLoop(ctx context.Context) {
+	for {
+		dat, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		message := wsMessage{}
+		if err := json.Unmarshal(dat, &message); err != nil {
+			c.enqueueError("", "Invalid message")
+			continue
+		}
+
+		switch message.Type {
+		case WS_MESSAGE_SUBSCRIBE:
+			if msg := c.validateChannel(ctx, message.Channel); msg != "" {
+				c.enqueueError(message.Channel, msg)
+				continue
+			}
+
+			c.mu.Lock()
+			full := !c.channels[message.Channel] && len(c.channels) >= MAX_WS_CHANNELS
+			if !full {
+				c.channels[message.Channel] = true
+			}
+			c.mu.Unlock()
+
+			if full {
+				c.enqueueError(message.Channel, "Too many subscriptions")
+				continue
+			}
+			c.enqueue(wsMessage{Type: WS_MESSAGE_SUBSCRIBED, Channel: message.Channel})
+		case WS_MESSAGE_UNSUBSCRIBE:
+			c.mu.Lock()
+			delete(c.channels, message.Channel)
+			c.mu.Unlock()
+
+			c.enqueue(wsMessage{Type: WS_MESSAGE_UNSUBSCRIBED, Channel: message.Channel})
+		default:
+			c.enqueueError("", "Unknown message type")
+		}
+	}
+}
+
+// validateChannel returns why the client can't subscribe to channel, or
+// an empty string if it can
+func (c *wsClient) validateChannel(ctx context.Context, channel string) string {
+	if channel == WS_CHANNEL_TIMELINE || channel == WS_CHANNEL_NOTIFICATIONS {
+		return ""
+	}
+
+	chirpIDString, ok := strings.CutPrefix(channel, WS_CHANNEL_THREAD_PREFIX)
+	if !ok {
+		return "Unknown channel"
+	}
+	chirpID, err := uuid.Parse(chirpIDString)
+	if err != nil {
+		return "Invalid chirp ID"
+	}
+
+	if _, err := c.cfg.DbQueries.GetVisibleChirp(ctx, database.GetVisibleChirpParams{
+		ID:       chirpID,
+		ViewerID: c.userID,
+	}); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("%v\n", err)
+		}
+		return "Chirp not found"
+	}
+	return ""
+}
+
+func (c *wsClient) writeLoop(ctx context.Context) {
+	heartbeat := time.NewTicker(WS_PING_INTERVAL)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case dat := <-c.send:
+			err = c.conn.WriteText(dat)
+		case <-heartbeat.C:
+			err = c.conn.Ping()
+		}
+
+		// Closing ends the read loop and with it the connection's handler
+		if err != nil {
+			c.conn.Close(websocket.CloseGoingAway, "")
+			return
+		}
+	}
+}
+
+func (c *wsClient) eventLoop(ctx context.Context, sub *events.Subscription) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, open := <-sub.C:
+			// Dropped by the hub, events were lost so the client has to
+			// reconnect and reload
+			if !open {
+				c.conn.Close(websocket.CloseTryAgainLater, "Events were lost, reconnect")
+				return
+			}
+			if err := c.handleEvent(ctx, event); err != nil {
+				log.Printf("%v\n", err)
+			}
+		}
+	}
+}
+
+func (c *wsClient) handleEvent(ctx context.Context, event events.Event) error {
+	if event.Suspends(c.userID) {
+		c.conn.Close(websocket.ClosePolicyViolation, "Account suspended")
+		return nil
+	}
+	if event.Kind == events.KindNotificationCreated {
+		if event.UserID != c.userID || !c.subscribed(WS_CHANNEL_NOTIFICATIONS) {
+			return nil
+		}
+		return c.sendNotification(ctx, event)
+	}
+	if !event.IsChirpEvent() {
+		return nil
+	}
+
+	channels := []string{}
+	if thread := WS_CHANNEL_THREAD_PREFIX + event.ChirpID.String(); c.subscribed(thread) {
+		channels = append(channels, thread)
+	}
+	if c.subscribed(WS_CHANNEL_TIMELINE) {
+		onTimeline := event.AuthorID == c.userID
+		if !onTimeline {
+			following, err := c.cfg.DbQueries.IsFollowing(ctx, database.IsFollowingParams{
+				FollowerID: c.userID,
+				FolloweeID: event.AuthorID,
+			})
+			if err != nil {
+				return err
+			}
+			onTimeline = following
+		}
+		if onTimeline {
+			channels = append(channels, WS_CHANNEL_TIMELINE)
+		}
+	}
+	if len(channels) == 0 {
+		return nil
+	}
+
+	data, ok, err := c.cfg.chirpEventData(ctx, c.userID, event)
+	if err != nil || !ok {
+		return err
+	}
+	for _, channel := range channels {
+		c.enqueue(wsMessage{Type: event.Kind, Channel: channel, Data: data})
+	}
+	return nil
+}
+
+func (c *wsClient) sendNotification(ctx context.Context, event events.Event) error {
+	notification, err := c.cfg.DbQueries.GetNotification(ctx, database.GetNotificationParams{
+		ID:     event.NotificationID,
+		UserID: c.userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// From someone the user blocked or muted since
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	type notificationEventJson struct {
+		Id        string    `json:"id"`
+		Kind      string    `json:"kind"`
+		Message   string    `json:"message"`
+		ChirpID   *string   `json:"chirp_id"`
+		ActorID   *string   `json:"actor_id"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	data := notificationEventJson{
+		Id:        notification.ID.String(),
+		Kind:      notification.Kind,
+		Message:   notificationMessage(notification.Kind, 1),
+		CreatedAt: notification.CreatedAt,
+	}
+	if notification.ChirpID.Valid {
+		chirpID := notification.ChirpID.UUID.String()
+		data.ChirpID = &chirpID
+	}
+	if notification.ActorID.Valid {
+		actorID := notification.ActorID.UUID.String()
+		data.ActorID = &actorID
+	}
+
+	c.enqueue(wsMessage{Type: event.Kind, Channel: WS_CHANNEL_NOTIFICATIONS, Data: data})
+	return nil
+}
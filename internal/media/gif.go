@@ -0,0 +1,58 @@
+package media
+
+// gifFrames walks the blocks of a GIF without decoding any image data and
+// returns how many frames it has and how many pixels they add up to, which
+// is what decoding it would allocate. Malformed data is left for the
+// decoder to reject, the walk just stops there.
+func gifFrames(data []byte) (frames int, pixels int) {
+	// Header and logical screen descriptor, then the global color table
+	pos := 13
+	if len(data) < pos {
+		return 0, 0
+	}
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+
+	skipSubBlocks := func() {
+		for pos < len(data) {
+			size := int(data[pos])
+			pos++
+			if size == 0 {
+				return
+			}
+			pos += size
+		}
+	}
+
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21:
+			// Extension: introducer, label and data sub-blocks
+			pos += 2
+			skipSubBlocks()
+		case 0x2C:
+			// Image descriptor, then its local color table, the LZW
+			// minimum code size and the image data sub-blocks
+			if pos+10 > len(data) {
+				return frames, pixels
+			}
+			width := int(data[pos+5]) | int(data[pos+6])<<8
+			height := int(data[pos+7]) | int(data[pos+8])<<8
+			packed := data[pos+9]
+			pos += 10
+			if packed&0x80 != 0 {
+				pos += 3 << (packed&0x07 + 1)
+			}
+			pos++
+			skipSubBlocks()
+
+			frames++
+			pixels += width * height
+		default:
+			// Trailer
+			return frames, pixels
+		}
+	}
+	return frames, pixels
+}
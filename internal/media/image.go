@@ -0,0 +1,170 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxPixels bounds decoded image size to defuse decompression bombs,
+	// for animations the pixels of all frames together
+	MaxPixels     = 40_000_000
+	MaxGIFFrames  = 1000
+	ThumbnailSize = 320
+	jpegQuality   = 90
+)
+
+var ErrUnsupportedType = errors.New("unsupported image type")
+
+// Image is an uploaded image with its metadata stripped and a thumbnail
+type Image struct {
+	ContentType          string
+	Data                 []byte
+	Width                int
+	Height               int
+	Thumbnail            []byte
+	ThumbnailContentType string
+}
+
+// Process sniffs the content type of data from its bytes, ignoring whatever
+// the client claimed, and re-encodes the image. Re-encoding drops EXIF and
+// every other metadata block, including GPS positions embedded by phones,
+// so JPEGs are turned upright by their EXIF orientation first.
+func Process(data []byte) (Image, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return Image{}, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("cannot read image: %w", err)
+	}
+	if config.Width*config.Height > MaxPixels {
+		return Image{}, fmt.Errorf("image is too large: %dx%d", config.Width, config.Height)
+	}
+
+	var cleaned bytes.Buffer
+	var first image.Image
+	width, height := config.Width, config.Height
+
+	switch contentType {
+	case "image/gif":
+		// The header only sizes the screen, but decoding allocates every
+		// frame
+		frames, pixels := gifFrames(data)
+		if frames > MaxGIFFrames || pixels > MaxPixels {
+			return Image{}, fmt.Errorf("animation is too large: %d frames, %d pixels", frames, pixels)
+		}
+
+		// Keep animations, which image.Decode would flatten to one frame
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("cannot decode image: %w", err)
+		}
+		if err := gif.EncodeAll(&cleaned, anim); err != nil {
+			return Image{}, err
+		}
+		first = anim.Image[0]
+	default:
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, fmt.Errorf("cannot decode image: %w", err)
+		}
+		if contentType == "image/jpeg" {
+			img = orient(img, jpegOrientation(data))
+			width, height = img.Bounds().Dx(), img.Bounds().Dy()
+		}
+		if err := encode(&cleaned, img, contentType); err != nil {
+			return Image{}, err
+		}
+		first = img
+	}
+
+	thumbnailContentType := ThumbnailContentType(contentType)
+	var thumbnail bytes.Buffer
+	if err := encode(&thumbnail, Thumbnail(first, ThumbnailSize), thumbnailContentType); err != nil {
+		return Image{}, err
+	}
+
+	return Image{
+		ContentType:          contentType,
+		Data:                 cleaned.Bytes(),
+		Width:                width,
+		Height:               height,
+		Thumbnail:            thumbnail.Bytes(),
+		ThumbnailContentType: thumbnailContentType,
+	}, nil
+}
+
+// ThumbnailContentType is the type thumbnails of contentType images are
+// stored as. Thumbnails of GIFs are still images, PNG keeps transparency.
+func ThumbnailContentType(contentType string) string {
+	if contentType == "image/gif" {
+		return "image/png"
+	}
+	return contentType
+}
+
+func encode(buf *bytes.Buffer, img image.Image, contentType string) error {
+	switch contentType {
+	case "image/jpeg":
+		return jpeg.Encode(buf, img, &jpeg.Options{Quality: jpegQuality})
+	case "image/png":
+		return png.Encode(buf, img)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+}
+
+// Thumbnail scales img down to fit in a size x size box, keeping its aspect
+// ratio. Each target pixel averages a grid of samples from its source area.
+// Images that already fit are returned as they are.
+func Thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= size && srcH <= size {
+		return img
+	}
+
+	dstW, dstH := size, size
+	if srcW > srcH {
+		dstH = max(1, srcH*size/srcW)
+	} else {
+		dstW = max(1, srcW*size/srcH)
+	}
+
+	const samples = 4
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := range dstH {
+		for x := range dstW {
+			var r, g, b, a uint32
+			for sy := range samples {
+				for sx := range samples {
+					srcX := bounds.Min.X + (x*samples+sx)*srcW/(dstW*samples)
+					srcY := bounds.Min.Y + (y*samples+sy)*srcH/(dstH*samples)
+					pr, pg, pb, pa := img.At(srcX, srcY).RGBA()
+					r, g, b, a = r+pr, g+pg, b+pb, a+pa
+				}
+			}
+			n := uint32(samples * samples)
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,196 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"testing"
+)
+
+func testJPEG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("cannot encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// withExif inserts an APP1 Exif segment right after the JPEG SOI marker
+func withExif(data []byte, payload string) []byte {
+	segment := append([]byte("Exif\x00\x00"), payload...)
+	length := len(segment) + 2
+
+	out := []byte{0xFF, 0xD8, 0xFF, 0xE1, byte(length >> 8), byte(length)}
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestProcessStripsExif(t *testing.T) {
+	data := withExif(testJPEG(t, 800, 400), "GPS 51.5007N 0.1246W")
+
+	processed, err := Process(data)
+	if err != nil {
+		t.Fatalf("cannot process image: %v", err)
+	}
+
+	if processed.ContentType != "image/jpeg" {
+		t.Errorf("content type must be image/jpeg but got %s", processed.ContentType)
+	}
+	if bytes.Contains(processed.Data, []byte("Exif")) || bytes.Contains(processed.Data, []byte("GPS")) {
+		t.Errorf("processed image still contains EXIF data")
+	}
+	if processed.Width != 800 || processed.Height != 400 {
+		t.Errorf("size must be 800x400 but got %dx%d", processed.Width, processed.Height)
+	}
+
+	thumbnail, _, err := image.Decode(bytes.NewReader(processed.Thumbnail))
+	if err != nil {
+		t.Fatalf("cannot decode thumbnail: %v", err)
+	}
+	if bounds := thumbnail.Bounds(); bounds.Dx() != ThumbnailSize || bounds.Dy() != ThumbnailSize/2 {
+		t.Errorf("thumbnail must be %dx%d but got %dx%d", ThumbnailSize, ThumbnailSize/2, bounds.Dx(), bounds.Dy())
+	}
+}
+
+func TestProcessRejectsNonImages(t *testing.T) {
+	cases := [][]byte{
+		[]byte("<html><body>hello</body></html>"),
+		[]byte("%PDF-1.4 definitely a pdf"),
+		{},
+	}
+
+	for _, c := range cases {
+		if _, err := Process(c); !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("expected ErrUnsupportedType but got %v", err)
+		}
+	}
+}
+
+// orientationExif is a big-endian TIFF structure with just an Orientation
+// tag in its first IFD
+func orientationExif(orientation int) string {
+	return "MM\x00\x2A\x00\x00\x00\x08" +
+		"\x00\x01" +
+		"\x01\x12\x00\x03\x00\x00\x00\x01" + string([]byte{0, byte(orientation), 0, 0}) +
+		"\x00\x00\x00\x00"
+}
+
+// halvesJPEG is red on its left half and blue on its right half
+func halvesJPEG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= width/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("cannot encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func isRed(c color.Color) bool {
+	r, _, b, _ := c.RGBA()
+	return r > 0xC000 && b < 0x4000
+}
+
+func TestProcessAppliesExifOrientation(t *testing.T) {
+	cases := []struct {
+		orientation   int
+		width, height int
+		// Where the red left half of the original ends up
+		redAt, blueAt image.Point
+	}{
+		{orientation: 1, width: 40, height: 20, redAt: image.Pt(5, 10), blueAt: image.Pt(35, 10)},
+		{orientation: 3, width: 40, height: 20, redAt: image.Pt(35, 10), blueAt: image.Pt(5, 10)},
+		{orientation: 6, width: 20, height: 40, redAt: image.Pt(10, 5), blueAt: image.Pt(10, 35)},
+		{orientation: 8, width: 20, height: 40, redAt: image.Pt(10, 35), blueAt: image.Pt(10, 5)},
+	}
+
+	for _, c := range cases {
+		data := withExif(halvesJPEG(t, 40, 20), orientationExif(c.orientation))
+		if got := jpegOrientation(data); got != c.orientation {
+			t.Errorf("expected orientation %d but read %d", c.orientation, got)
+		}
+
+		processed, err := Process(data)
+		if err != nil {
+			t.Fatalf("cannot process image: %v", err)
+		}
+		if processed.Width != c.width || processed.Height != c.height {
+			t.Errorf("orientation %d: size must be %dx%d but got %dx%d", c.orientation, c.width, c.height, processed.Width, processed.Height)
+		}
+
+		img, err := jpeg.Decode(bytes.NewReader(processed.Data))
+		if err != nil {
+			t.Fatalf("cannot decode processed image: %v", err)
+		}
+		if !isRed(img.At(c.redAt.X, c.redAt.Y)) || isRed(img.At(c.blueAt.X, c.blueAt.Y)) {
+			t.Errorf("orientation %d: image was not turned upright", c.orientation)
+		}
+	}
+}
+
+func TestJPEGOrientationIgnoresInvalidExif(t *testing.T) {
+	if got := jpegOrientation(withExif(testJPEG(t, 10, 10), "GPS 51.5007N 0.1246W")); got != 1 {
+		t.Errorf("expected orientation 1 for unreadable EXIF but got %d", got)
+	}
+	if got := jpegOrientation(testJPEG(t, 10, 10)); got != 1 {
+		t.Errorf("expected orientation 1 without EXIF but got %d", got)
+	}
+}
+
+func testGIF(t *testing.T, frames, size int) []byte {
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{}
+	for i := range frames {
+		frame := image.NewPaletted(image.Rect(0, 0, size, size), palette)
+		frame.SetColorIndex(0, 0, uint8(i%2))
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatalf("cannot encode test animation: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestGIFFramesCountsWithoutDecoding(t *testing.T) {
+	frames, pixels := gifFrames(testGIF(t, 3, 16))
+	if frames != 3 || pixels != 3*16*16 {
+		t.Errorf("expected 3 frames of 256 pixels but got %d frames, %d pixels", frames, pixels)
+	}
+}
+
+func TestProcessLimitsGIFFrames(t *testing.T) {
+	processed, err := Process(testGIF(t, 3, 16))
+	if err != nil {
+		t.Fatalf("cannot process animation: %v", err)
+	}
+	anim, err := gif.DecodeAll(bytes.NewReader(processed.Data))
+	if err != nil || len(anim.Image) != 3 {
+		t.Errorf("animation must keep its 3 frames, got %v", err)
+	}
+
+	if _, err := Process(testGIF(t, MaxGIFFrames+1, 1)); err == nil {
+		t.Errorf("animation with more than %d frames must be rejected", MaxGIFFrames)
+	}
+}
@@ -0,0 +1,234 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// Action is what happens to a chirp matching a rule
+type Action string
+
+const (
+	ActionNone Action = ""
+	// ActionMask replaces the matched text with asterisks
+	ActionMask Action = "mask"
+	// ActionHold keeps the chirp back until a moderator approves it
+	ActionHold Action = "hold"
+	// ActionReject refuses the chirp outright
+	ActionReject Action = "reject"
+)
+
+// severity orders actions, the most severe matching rule wins
+var severity = map[Action]int{
+	ActionNone:   0,
+	ActionMask:   1,
+	ActionHold:   2,
+	ActionReject: 3,
+}
+
+// MostSevere returns whichever of a and b is the more severe action, for
+// callers checking several texts that make up one chirp
+func MostSevere(a, b Action) Action {
+	if severity[b] > severity[a] {
+		return b
+	}
+	return a
+}
+
+// Kind is how a rule's pattern is matched
+type Kind string
+
+const (
+	// KindWord matches whole words after normalization, see Normalize
+	KindWord Kind = "word"
+	// KindRegex matches a regular expression against the raw text
+	KindRegex Kind = "regex"
+)
+
+const mask = "****"
+
+type Rule struct {
+	ID      uuid.UUID
+	Kind    Kind
+	Pattern string
+	Action  Action
+}
+
+type Match struct {
+	RuleID uuid.UUID
+	Action Action
+}
+
+type Result struct {
+	// Body is the checked text with masked matches replaced
+	Body string
+	// Action is the most severe action of all matching rules
+	Action  Action
+	Matches []Match
+}
+
+type compiledRule struct {
+	Rule
+	word  string
+	regex *regexp.Regexp
+}
+
+func compile(rule Rule) (compiledRule, error) {
+	if _, ok := severity[rule.Action]; !ok || rule.Action == ActionNone {
+		return compiledRule{}, fmt.Errorf("unknown action %q", rule.Action)
+	}
+
+	switch rule.Kind {
+	case KindWord:
+		if strings.IndexFunc(strings.TrimSpace(rule.Pattern), unicode.IsSpace) >= 0 {
+			return compiledRule{}, errors.New("word rules must be a single word")
+		}
+		word := Normalize(rule.Pattern)
+		if word == "" {
+			return compiledRule{}, errors.New("word rules need at least one letter")
+		}
+		return compiledRule{Rule: rule, word: word}, nil
+	case KindRegex:
+		regex, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return compiledRule{}, err
+		}
+		if regex.MatchString("") {
+			return compiledRule{}, errors.New("regex rules must not match empty text")
+		}
+		return compiledRule{Rule: rule, regex: regex}, nil
+	default:
+		return compiledRule{}, fmt.Errorf("unknown kind %q", rule.Kind)
+	}
+}
+
+// Validate reports whether rule can be loaded into an Engine
+func Validate(rule Rule) error {
+	_, err := compile(rule)
+	return err
+}
+
+type ruleSet struct {
+	words   map[string][]compiledRule
+	regexes []compiledRule
+}
+
+// Engine checks text against a set of rules. Rules can be swapped with
+// Load at any time, concurrently with Check.
+type Engine struct {
+	rules atomic.Pointer[ruleSet]
+}
+
+func NewEngine() *Engine {
+	engine := &Engine{}
+	engine.rules.Store(&ruleSet{words: map[string][]compiledRule{}})
+	return engine
+}
+
+// Load replaces the engine's rules. Rules that don't compile are skipped
+// and reported in the returned error; the rest are loaded regardless.
+func (e *Engine) Load(rules []Rule) error {
+	set := &ruleSet{words: map[string][]compiledRule{}}
+	var errs []error
+
+	for _, rule := range rules {
+		compiled, err := compile(rule)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %w", rule.ID, err))
+			continue
+		}
+		if compiled.Kind == KindWord {
+			set.words[compiled.word] = append(set.words[compiled.word], compiled)
+		} else {
+			set.regexes = append(set.regexes, compiled)
+		}
+	}
+
+	e.rules.Store(set)
+	return errors.Join(errs...)
+}
+
+// maskWords matches token against the word rules, trying it with its
+// punctuation trimmed first, then with leetspeak symbols kept, then whole.
+// Only the matched part of the token is masked.
+func maskWords(set *ruleSet, token string, record func(compiledRule)) string {
+	strictStart, strictStop := trimPunctuation(token, false)
+	leetStart, leetStop := trimPunctuation(token, true)
+
+	for _, bounds := range [][2]int{{strictStart, strictStop}, {leetStart, leetStop}, {0, len(token)}} {
+		start, stop := bounds[0], bounds[1]
+		rules, ok := set.words[Normalize(token[start:stop])]
+		if !ok || start >= stop {
+			continue
+		}
+
+		masked := false
+		for _, rule := range rules {
+			record(rule)
+			masked = masked || rule.Action == ActionMask
+		}
+		if masked {
+			return token[:start] + mask + token[stop:]
+		}
+		return token
+	}
+
+	return token
+}
+
+// Check runs text through every rule. Word rules look at each
+// whitespace-separated token both with and without its surrounding
+// punctuation, so "Kerfuffle!" and "k3rfuffl3" match "kerfuffle" while
+// "kerfuffles" does not.
+func (e *Engine) Check(text string) Result {
+	set := e.rules.Load()
+	result := Result{Action: ActionNone}
+
+	record := func(rule compiledRule) {
+		result.Matches = append(result.Matches, Match{RuleID: rule.ID, Action: rule.Action})
+		if severity[rule.Action] > severity[result.Action] {
+			result.Action = rule.Action
+		}
+	}
+
+	var body strings.Builder
+	rest := text
+	for rest != "" {
+		// Copy whitespace through, then take the next token
+		space := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsSpace(r) })
+		if space < 0 {
+			body.WriteString(rest)
+			break
+		}
+		body.WriteString(rest[:space])
+		rest = rest[space:]
+
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end < 0 {
+			end = len(rest)
+		}
+		token := rest[:end]
+		rest = rest[end:]
+
+		body.WriteString(maskWords(set, token, record))
+	}
+	result.Body = body.String()
+
+	for _, rule := range set.regexes {
+		if !rule.regex.MatchString(result.Body) {
+			continue
+		}
+		record(rule)
+		if rule.Action == ActionMask {
+			result.Body = rule.regex.ReplaceAllLiteralString(result.Body, mask)
+		}
+	}
+
+	return result
+}
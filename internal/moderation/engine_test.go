@@ -0,0 +1,119 @@
+package moderation
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"Kerfuffle":  "kerfuffle",
+		"k3rfuffl3":  "kerfuffle",
+		"K3rfüffl3":  "kerfuffle",
+		"K3rfüff!e":  "kerfuffie", // "!" reads as "i"
+		"K.E.R.F":    "kerf",
+		"$harb3rt":   "sharbert",
+		"fórnäx":     "fornax",
+		"ＦＯＲＮＡＸ":     "fornax",
+		"fоrnах":     "fornax", // Cyrillic о, а and х
+		"hello, 123": "helloie",
+	}
+
+	for input, expected := range cases {
+		if actual := Normalize(input); actual != expected {
+			t.Errorf("Normalize(%q) = %q, expected %q", input, actual, expected)
+		}
+	}
+}
+
+func newTestEngine(t *testing.T, rules ...Rule) *Engine {
+	engine := NewEngine()
+	if err := engine.Load(rules); err != nil {
+		t.Fatalf("cannot load rules: %v", err)
+	}
+	return engine
+}
+
+func TestCheckMasksWords(t *testing.T) {
+	engine := newTestEngine(t,
+		Rule{ID: uuid.New(), Kind: KindWord, Pattern: "kerfuffle", Action: ActionMask},
+		Rule{ID: uuid.New(), Kind: KindWord, Pattern: "sharbert", Action: ActionMask},
+	)
+
+	cases := map[string]string{
+		"What a Kerfuffle!":         "What a ****!",
+		"such a k3rfuffl3 today":    "such a **** today",
+		"\"$harbert\", he said":     "\"****\", he said",
+		"kerfuffles are fine":       "kerfuffles are fine",
+		"no  double\tspace changes": "no  double\tspace changes",
+	}
+
+	for input, expected := range cases {
+		if actual := engine.Check(input).Body; actual != expected {
+			t.Errorf("Check(%q).Body = %q, expected %q", input, actual, expected)
+		}
+	}
+}
+
+func TestCheckPicksMostSevereAction(t *testing.T) {
+	rejectID := uuid.New()
+	engine := newTestEngine(t,
+		Rule{ID: uuid.New(), Kind: KindWord, Pattern: "fornax", Action: ActionMask},
+		Rule{ID: uuid.New(), Kind: KindRegex, Pattern: `(?i)buy now`, Action: ActionHold},
+		Rule{ID: rejectID, Kind: KindRegex, Pattern: `https?://evil\.example`, Action: ActionReject},
+	)
+
+	result := engine.Check("fornax, buy now")
+	if result.Action != ActionHold {
+		t.Errorf("action must be hold but got %q", result.Action)
+	}
+	if result.Body != "****, buy now" {
+		t.Errorf("unexpected body %q", result.Body)
+	}
+
+	result = engine.Check("BUY NOW at http://evil.example")
+	if result.Action != ActionReject {
+		t.Errorf("action must be reject but got %q", result.Action)
+	}
+	if len(result.Matches) != 2 || result.Matches[1].RuleID != rejectID {
+		t.Errorf("unexpected matches %v", result.Matches)
+	}
+
+	if result := engine.Check("all good"); result.Action != ActionNone || len(result.Matches) != 0 {
+		t.Errorf("clean text must not match but got %v", result)
+	}
+}
+
+func TestMostSevere(t *testing.T) {
+	cases := []struct {
+		a, b, want Action
+	}{
+		{ActionNone, ActionNone, ActionNone},
+		{ActionNone, ActionMask, ActionMask},
+		{ActionHold, ActionMask, ActionHold},
+		{ActionHold, ActionReject, ActionReject},
+	}
+	for _, c := range cases {
+		if got := MostSevere(c.a, c.b); got != c.want {
+			t.Errorf("MostSevere(%q, %q) = %q, want %q", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestLoadSkipsInvalidRules(t *testing.T) {
+	engine := NewEngine()
+	err := engine.Load([]Rule{
+		{ID: uuid.New(), Kind: KindRegex, Pattern: "(", Action: ActionReject},
+		{ID: uuid.New(), Kind: KindWord, Pattern: "two words", Action: ActionMask},
+		{ID: uuid.New(), Kind: KindWord, Pattern: "fornax", Action: "delete"},
+		{ID: uuid.New(), Kind: KindWord, Pattern: "sharbert", Action: ActionReject},
+	})
+	if err == nil {
+		t.Errorf("expected an error for invalid rules")
+	}
+
+	if result := engine.Check("sharbert"); result.Action != ActionReject {
+		t.Errorf("valid rules must still load but got %q", result.Action)
+	}
+}
@@ -0,0 +1,95 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+)
+
+// leetspeak maps digits and symbols commonly swapped in for letters
+var leetspeak = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'8': 'b',
+	'9': 'g',
+	'@': 'a',
+	'$': 's',
+	'!': 'i',
+	'|': 'i',
+	'+': 't',
+}
+
+// confusables maps lowercase letters that look like Latin ones, mostly
+// Cyrillic and Greek homoglyphs and accented Latin letters, to plain ASCII
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'з': '3', 'и': 'u', 'і': 'i',
+	'ї': 'i', 'ј': 'j', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'п': 'n',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's', 'ԁ': 'd',
+	'ԛ': 'q', 'ԝ': 'w', 'ь': 'b',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v',
+	'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w', 'γ': 'y',
+	// Latin with diacritics
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a', 'ā': 'a',
+	'ç': 'c', 'ć': 'c', 'č': 'c',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e', 'ē': 'e', 'ė': 'e', 'ę': 'e',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i', 'ī': 'i', 'ı': 'i',
+	'ñ': 'n', 'ń': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ø': 'o', 'ō': 'o',
+	'ś': 's', 'š': 's', 'ß': 's',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u', 'ū': 'u',
+	'ý': 'y', 'ÿ': 'y',
+	'ź': 'z', 'ż': 'z', 'ž': 'z',
+	// Other lookalikes
+	'ɡ': 'g', 'ɩ': 'i', 'ℓ': 'l',
+}
+
+// Normalize folds text to the form word rules are matched in: lowercase
+// ASCII letters only, with fullwidth forms, homoglyphs, accents and
+// leetspeak undone, and everything else, punctuation included, removed.
+// "K3rfüffl3" and "ＫＥＲＦＵＦＦＬＥ" both normalize to "kerfuffle".
+func Normalize(text string) string {
+	var normalized strings.Builder
+	for _, r := range text {
+		// Fullwidth ASCII variants, e.g. "Ａ"
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+
+		r = unicode.ToLower(r)
+		if mapped, ok := confusables[r]; ok {
+			r = mapped
+		}
+		if mapped, ok := leetspeak[r]; ok {
+			r = mapped
+		}
+
+		if r >= 'a' && r <= 'z' {
+			normalized.WriteRune(r)
+		}
+	}
+	return normalized.String()
+}
+
+// trimPunctuation strips leading and trailing runes that are neither
+// letters nor digits, returning the byte offsets of what is left. With
+// keepLeet, leetspeak symbols such as the "$" in "$harbert" are kept.
+func trimPunctuation(token string, keepLeet bool) (int, int) {
+	isEdge := func(r rune) bool {
+		if _, ok := leetspeak[r]; ok && keepLeet {
+			return false
+		}
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}
+
+	start := len(token) - len(strings.TrimLeftFunc(token, isEdge))
+	end := len(strings.TrimRightFunc(token, isEdge))
+	if start >= end {
+		return 0, 0
+	}
+	return start, end
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is a minimal in-memory stand-in for an S3-compatible service
+type fakeS3 struct {
+	mu        sync.Mutex
+	accessKey string
+	objects   map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential="+f.accessKey+"/") ||
+		!strings.Contains(auth, "SignedHeaders=host;x-amz-content-sha256;x-amz-date") ||
+		req.Header.Get("X-Amz-Date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	body, _ := io.ReadAll(req.Body)
+	sum := sha256.Sum256(body)
+	if req.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch req.Method {
+	case http.MethodPut:
+		f.objects[req.URL.Path] = body
+	case http.MethodGet:
+		object, ok := f.objects[req.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(object)
+	case http.MethodDelete:
+		delete(f.objects, req.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func testBlobStore(t *testing.T, store BlobStore) {
+	ctx := context.Background()
+	data := []byte("not really an image")
+
+	if err := store.Put(ctx, "media/abc", data, "image/png"); err != nil {
+		t.Fatalf("cannot put blob: %v", err)
+	}
+
+	blob, err := store.Get(ctx, "media/abc")
+	if err != nil {
+		t.Fatalf("cannot get blob: %v", err)
+	}
+	got, _ := io.ReadAll(blob)
+	blob.Close()
+	if !bytes.Equal(got, data) {
+		t.Errorf("blob must be %q but got %q", data, got)
+	}
+
+	if err := store.Delete(ctx, "media/abc"); err != nil {
+		t.Fatalf("cannot delete blob: %v", err)
+	}
+
+	if _, err := store.Get(ctx, "media/abc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted blob must be ErrNotFound but got %v", err)
+	}
+
+	if err := store.Delete(ctx, "media/missing"); err != nil {
+		t.Errorf("deleting a missing blob must succeed but got %v", err)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("cannot create local store: %v", err)
+	}
+
+	testBlobStore(t, store)
+
+	if err := store.Put(context.Background(), "../escape", []byte{}, "text/plain"); err == nil {
+		t.Errorf("keys escaping the root must be rejected")
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{
+		accessKey: "test-access-key",
+		objects:   map[string][]byte{},
+	})
+	defer server.Close()
+
+	store, err := NewS3Store(server.URL, "chirpy", "us-east-1", "test-access-key", "test-secret-key")
+	if err != nil {
+		t.Fatalf("cannot create s3 store: %v", err)
+	}
+
+	testBlobStore(t, store)
+
+	badStore, _ := NewS3Store(server.URL, "chirpy", "us-east-1", "wrong-key", "test-secret-key")
+	if err := badStore.Put(context.Background(), "media/abc", []byte("x"), "image/png"); err == nil {
+		t.Errorf("put with wrong credentials must fail")
+	}
+}
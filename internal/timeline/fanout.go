@@ -0,0 +1,126 @@
+package timeline
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	// backfillSize is how many recent chirps are copied into a timeline
+	// when a user follows a regular (non fan-out-on-read) account
+	backfillSize = 100
+)
+
+// Fanout materializes home timelines. Chirps are pushed into
+// timeline_entries by background workers (fan-out-on-write) for follows
+// made while the followee had at most threshold followers. Later follows
+// of the account are merged in at read time instead (fan-out-on-read), so
+// a single chirp never turns into more than threshold inserts. The mode
+// is recorded on each follow, so an account crossing the threshold never
+// drops out of anyone's timeline.
+type Fanout struct {
+	db        *database.Queries
+	threshold int64
+	jobs      chan database.Chirp
+}
+
+func NewFanout(db *database.Queries, threshold int64, queueSize int) *Fanout {
+	return &Fanout{
+		db:        db,
+		threshold: threshold,
+		jobs:      make(chan database.Chirp, queueSize),
+	}
+}
+
+// Start launches the given number of fan-out workers. They stop once ctx is done.
+func (f *Fanout) Start(ctx context.Context, workers int) {
+	for range workers {
+		go f.work(ctx)
+	}
+}
+
+// Enqueue schedules a freshly created chirp for fan-out. When the queue is
+// full the chirp is fanned out synchronously so no follower misses it.
+func (f *Fanout) Enqueue(chirp database.Chirp) {
+	select {
+	case f.jobs <- chirp:
+	default:
+		log.Printf("fanout queue is full, fanning out chirp %s inline\n", chirp.ID)
+		if err := f.fanOut(context.Background(), chirp); err != nil {
+			log.Printf("%v\n", err)
+		}
+	}
+}
+
+func (f *Fanout) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case chirp := <-f.jobs:
+			if err := f.fanOut(ctx, chirp); err != nil {
+				log.Printf("%v\n", err)
+			}
+		}
+	}
+}
+
+func (f *Fanout) fanOut(ctx context.Context, chirp database.Chirp) error {
+	return f.db.FanOutChirp(ctx, database.FanOutChirpParams{
+		ChirpID:   chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		AuthorID:  chirp.UserID,
+	})
+}
+
+// Threshold is the follower count from which new follows of an account
+// are served with fan-out-on-read
+func (f *Fanout) Threshold() int64 {
+	return f.threshold
+}
+
+// Follow copies the most recent chirps of followee into the follower's
+// timeline, unless the follow is served with fan-out-on-read.
+func (f *Fanout) Follow(ctx context.Context, followerID, followeeID uuid.UUID, fanoutOnRead bool) error {
+	if fanoutOnRead {
+		return nil
+	}
+
+	return f.db.BackfillTimeline(ctx, database.BackfillTimelineParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+		MaxEntries: backfillSize,
+	})
+}
+
+// Unfollow removes chirps of followee from the follower's timeline.
+func (f *Fanout) Unfollow(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	return f.db.RemoveTimelineEntriesFromAuthor(ctx, database.RemoveTimelineEntriesFromAuthorParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+}
+
+// Home returns the user's home timeline from the materialized entries,
+// merging in chirps from the follows served with fan-out-on-read.
+func (f *Fanout) Home(ctx context.Context, userID uuid.UUID, before time.Time, limit int32) ([]database.Chirp, error) {
+	return f.db.GetTimelineMaterialized(ctx, database.GetTimelineMaterializedParams{
+		UserID:     userID,
+		Before:     before,
+		MaxResults: limit,
+	})
+}
+
+// HomeOnRead builds the same timeline purely by joining follows and chirps.
+// It is kept as the baseline the materialized strategy is measured against.
+func (f *Fanout) HomeOnRead(ctx context.Context, userID uuid.UUID, before time.Time, limit int32) ([]database.Chirp, error) {
+	return f.db.GetTimelineFanoutRead(ctx, database.GetTimelineFanoutReadParams{
+		Before:     before,
+		UserID:     userID,
+		MaxResults: limit,
+	})
+}
@@ -0,0 +1,114 @@
+package timeline
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+const (
+	benchAuthors         = 200
+	benchChirpsPerAuthor = 50
+	benchPageSize        = 20
+)
+
+// seedTimeline creates a reader following benchAuthors accounts, each with
+// benchChirpsPerAuthor chirps fanned out into the reader's timeline.
+func seedTimeline(b *testing.B, db *database.Queries, f *Fanout) uuid.UUID {
+	ctx := context.Background()
+	created := []uuid.UUID{}
+	b.Cleanup(func() {
+		for _, id := range created {
+			db.DeleteUser(ctx, id)
+		}
+	})
+
+	newUser := func(email string) uuid.UUID {
+		user, err := db.CreateUser(ctx, database.CreateUserParams{
+			Email:          email,
+			HashedPassword: "unset",
+		})
+		if err != nil {
+			b.Fatalf("cannot create user: %v", err)
+		}
+		created = append(created, user.ID)
+		return user.ID
+	}
+
+	run := uuid.NewString()
+	readerID := newUser(fmt.Sprintf("reader-%s@bench.local", run))
+
+	for i := range benchAuthors {
+		authorID := newUser(fmt.Sprintf("author-%d-%s@bench.local", i, run))
+		if _, err := db.FollowUser(ctx, database.FollowUserParams{
+			FollowerID:      readerID,
+			FolloweeID:      authorID,
+			FanoutThreshold: f.Threshold(),
+		}); err != nil {
+			b.Fatalf("cannot follow: %v", err)
+		}
+
+		for j := range benchChirpsPerAuthor {
+			chirp, err := db.CreateChirp(ctx, database.CreateChirpParams{
+				Body:       fmt.Sprintf("chirp %d", j),
+				UserID:     authorID,
+				Visibility: "public",
+			})
+			if err != nil {
+				b.Fatalf("cannot create chirp: %v", err)
+			}
+			if err := f.fanOut(ctx, chirp); err != nil {
+				b.Fatalf("cannot fan out chirp: %v", err)
+			}
+		}
+	}
+
+	return readerID
+}
+
+func BenchmarkHomeTimeline(b *testing.B) {
+	dbURL := os.Getenv("DB_URL")
+	if dbURL == "" {
+		b.Skip("DB_URL is not set")
+	}
+
+	conn, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		b.Fatalf("cannot open database: %v", err)
+	}
+	defer conn.Close()
+
+	db := database.New(conn)
+	f := NewFanout(db, benchAuthors, 0)
+	readerID := seedTimeline(b, db, f)
+	before := time.Now().UTC().Add(time.Hour)
+
+	strategies := []struct {
+		name string
+		read func(context.Context, uuid.UUID, time.Time, int32) ([]database.Chirp, error)
+	}{
+		{"fanout-on-write", f.Home},
+		{"fanout-on-read", f.HomeOnRead},
+	}
+
+	for _, s := range strategies {
+		b.Run(s.name, func(b *testing.B) {
+			for b.Loop() {
+				chirps, err := s.read(context.Background(), readerID, before, benchPageSize)
+				if err != nil {
+					b.Fatalf("cannot read timeline: %v", err)
+				}
+				if len(chirps) != benchPageSize {
+					b.Fatalf("expected %d chirps but got %d", benchPageSize, len(chirps))
+				}
+			}
+		})
+	}
+}
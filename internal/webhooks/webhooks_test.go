@@ -0,0 +1,124 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"chirp.created"}`)
+
+	signature := Sign("whsec_test", timestamp, body)
+	if !strings.HasPrefix(signature, "v1=") || len(signature) != len("v1=")+64 {
+		t.Fatalf("unexpected signature format %s", signature)
+	}
+	if Sign("whsec_test", timestamp, body) != signature {
+		t.Error("signing must be deterministic")
+	}
+	if Sign("whsec_other", timestamp, body) == signature {
+		t.Error("signature must depend on the secret")
+	}
+	if Sign("whsec_test", timestamp.Add(time.Second), body) == signature {
+		t.Error("signature must depend on the timestamp")
+	}
+	if Sign("whsec_test", timestamp, []byte(`{}`)) == signature {
+		t.Error("signature must depend on the body")
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	first, err := NewSecret()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, _ := NewSecret()
+	if !strings.HasPrefix(first, "whsec_") || first == second {
+		t.Errorf("expected distinct prefixed secrets, got %s and %s", first, second)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{10, 4*time.Hour + 16*time.Minute},
+		{11, 6 * time.Hour},
+		{100, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.expected {
+			t.Errorf("Backoff(%d) = %v, expected %v", tt.attempts, got, tt.expected)
+		}
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":        true,
+		"2606:2800:220:1::248": true,
+		"127.0.0.1":            false,
+		"10.1.2.3":             false,
+		"192.168.0.10":         false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"::1":                  false,
+		"fd00::1":              false,
+		"::ffff:127.0.0.1":     false,
+		"0.0.0.0":              false,
+	}
+	for address, expected := range tests {
+		if got := isPublic(netip.MustParseAddr(address)); got != expected {
+			t.Errorf("isPublic(%s) = %v, expected %v", address, got, expected)
+		}
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	body := []byte(`{"type":"user.upgraded"}`)
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received, _ := io.ReadAll(req.Body)
+		timestamp, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil ||
+			req.Header.Get(HeaderID) != "event-1" ||
+			req.Header.Get(HeaderEvent) != "user.upgraded" ||
+			req.Header.Get(HeaderSignature) != Sign("secret", time.Unix(timestamp, 0), received) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := NewClient(time.Second, true)
+	code, err := Deliver(context.Background(), client, server.URL, "secret", "event-1", "user.upgraded", body)
+	if err != nil || code != http.StatusNoContent {
+		t.Errorf("expected a signed delivery to succeed, got %d %v", code, err)
+	}
+
+	status = http.StatusServiceUnavailable
+	code, err = Deliver(context.Background(), client, server.URL, "secret", "event-1", "user.upgraded", body)
+	statusErr := &StatusError{}
+	if !errors.As(err, &statusErr) || code != http.StatusServiceUnavailable {
+		t.Errorf("expected a status error, got %d %v", code, err)
+	}
+
+	// The test server listens on loopback
+	_, err = Deliver(context.Background(), NewClient(time.Second, false), server.URL, "secret", "event-1", "user.upgraded", body)
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("expected loopback to be refused, got %v", err)
+	}
+}
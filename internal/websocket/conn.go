@@ -0,0 +1,320 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Close codes from RFC 6455
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseInvalidData     = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseTryAgainLater   = 1013
+)
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	// acceptGUID is appended to the client's key to compute the handshake
+	// response, as fixed by RFC 6455
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	writeTimeout        = 10 * time.Second
+	maxControlFrameSize = 125
+)
+
+var ErrClosed = errors.New("websocket: connection closed")
+
+// CloseError is returned by ReadMessage once the peer closed the connection
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: closed by peer with %d %s", e.Code, e.Reason)
+}
+
+// Conn is the server side of a WebSocket connection. One goroutine may
+// read while others write, writes are serialized.
+type Conn struct {
+	conn           net.Conn
+	reader         *bufio.Reader
+	maxMessageSize int64
+	idleTimeout    time.Duration
+
+	writeMu   sync.Mutex
+	closeSent bool
+	closeOnce sync.Once
+}
+
+// Upgrade completes the WebSocket handshake for req and takes over its
+// connection. On failure the HTTP error response has been written. Peers
+// silent for longer than idleTimeout, not even answering pings, are
+// considered dead. Messages larger than maxMessageSize close the
+// connection.
+func Upgrade(w http.ResponseWriter, req *http.Request, maxMessageSize int64, idleTimeout time.Duration) (*Conn, error) {
+	if req.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil, errors.New("websocket: handshake must be a GET request")
+	}
+	if !headerContains(req.Header, "Connection", "upgrade") || !headerContains(req.Header, "Upgrade", "websocket") {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, errors.New("websocket: not a websocket handshake")
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		w.WriteHeader(http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		w.WriteHeader(http.StatusBadRequest)
+		return nil, errors.New("websocket: invalid Sec-WebSocket-Key")
+	}
+
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, err
+	}
+	// Clients must wait for the handshake before sending frames
+	if rw.Reader.Buffered() > 0 {
+		netConn.Close()
+		return nil, errors.New("websocket: data sent before handshake")
+	}
+
+	netConn.SetDeadline(time.Now().Add(writeTimeout))
+	if _, err := io.WriteString(netConn, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: "+acceptKey(key)+"\r\n\r\n"); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	netConn.SetDeadline(time.Time{})
+
+	return newConn(netConn, rw.Reader, maxMessageSize, idleTimeout), nil
+}
+
+func newConn(netConn net.Conn, reader *bufio.Reader, maxMessageSize int64, idleTimeout time.Duration) *Conn {
+	return &Conn{
+		conn:           netConn,
+		reader:         reader,
+		maxMessageSize: maxMessageSize,
+		idleTimeout:    idleTimeout,
+	}
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered
+// and pongs skipped along the way. It returns a *CloseError once the peer
+// closes the connection; on protocol violations it closes the connection
+// itself.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	messageOpcode := -1
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			closeErr := &CloseError{Code: CloseNormal}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+			}
+			c.Close(closeErr.Code, "")
+			return nil, closeErr
+		case opText, opBinary:
+			if messageOpcode != -1 {
+				return nil, c.fail(CloseProtocolError, errors.New("websocket: new message before the last one ended"))
+			}
+			messageOpcode = int(opcode)
+		case opContinuation:
+			if messageOpcode == -1 {
+				return nil, c.fail(CloseProtocolError, errors.New("websocket: continuation without a message"))
+			}
+		default:
+			return nil, c.fail(CloseProtocolError, fmt.Errorf("websocket: unknown opcode %d", opcode))
+		}
+
+		if int64(len(message)+len(payload)) > c.maxMessageSize {
+			return nil, c.fail(CloseMessageTooBig, errors.New("websocket: message too big"))
+		}
+		message = append(message, payload...)
+
+		if fin {
+			if messageOpcode == opText && !utf8.Valid(message) {
+				return nil, c.fail(CloseInvalidData, errors.New("websocket: text message is not valid UTF-8"))
+			}
+			return message, nil
+		}
+	}
+}
+
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	if c.idleTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+	}
+
+	header := [2]byte{}
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, errors.New("websocket: reserved bits set"))
+	}
+	// Clients must mask every frame they send
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, errors.New("websocket: unmasked client frame"))
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		extended := [2]byte{}
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		extended := [8]byte{}
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+
+	if opcode >= opClose && (!fin || length > maxControlFrameSize) {
+		return false, 0, nil, c.fail(CloseProtocolError, errors.New("websocket: invalid control frame"))
+	}
+	if length > uint64(c.maxMessageSize) {
+		return false, 0, nil, c.fail(CloseMessageTooBig, errors.New("websocket: message too big"))
+	}
+
+	mask := [4]byte{}
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// WriteText sends data as a single text message
+func (c *Conn) WriteText(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+// Ping asks the peer for a pong, which keeps a live connection from
+// reaching its idle timeout
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+// Close sends a close frame with code and reason, unless one was sent
+// already, and closes the connection. It is safe to call more than once.
+func (c *Conn) Close(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason[:min(len(reason), maxControlFrameSize-2)]...)
+	err := c.writeFrame(opClose, payload)
+	if errors.Is(err, ErrClosed) {
+		err = nil
+	}
+
+	c.closeOnce.Do(func() {
+		if closeErr := c.conn.Close(); err == nil {
+			err = closeErr
+		}
+	})
+	return err
+}
+
+func (c *Conn) fail(code int, err error) error {
+	c.Close(code, "")
+	return err
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrClosed
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+
+	// Server frames are never masked
+	frame := []byte{0x80 | opcode}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	frame = append(frame, payload...)
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := c.conn.Write(frame)
+	return err
+}
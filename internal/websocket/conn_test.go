@@ -0,0 +1,241 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clientFrame builds a masked frame as a client would send it
+func clientFrame(fin bool, opcode byte, payload []byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first, 0x80 | byte(len(payload))}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+// readServerFrame reads one unmasked frame with a short payload
+func readServerFrame(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, header[1]&0x7f)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0] & 0x0f, payload, nil
+}
+
+func pipe(maxMessageSize int64) (*Conn, net.Conn) {
+	server, client := net.Pipe()
+	return newConn(server, bufio.NewReader(server), maxMessageSize, time.Second), client
+}
+
+func TestAcceptKey(t *testing.T) {
+	// Example from RFC 6455, section 1.3
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected accept key %s", got)
+	}
+}
+
+func TestReadMessageReassemblesAndAnswersPings(t *testing.T) {
+	conn, client := pipe(1024)
+	defer client.Close()
+
+	go func() {
+		client.Write(clientFrame(false, opText, []byte("Hel")))
+		client.Write(clientFrame(true, opPing, []byte("beat")))
+		client.Write(clientFrame(true, opContinuation, []byte("lo")))
+	}()
+	pong := make(chan []byte)
+	go func() {
+		opcode, payload, err := readServerFrame(client)
+		if err != nil || opcode != opPong {
+			t.Errorf("expected a pong, got opcode %d %v", opcode, err)
+		}
+		pong <- payload
+	}()
+
+	message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(message) != "Hello" {
+		t.Errorf("expected Hello, got %q", message)
+	}
+	if payload := <-pong; string(payload) != "beat" {
+		t.Errorf("pong must echo the ping, got %q", payload)
+	}
+}
+
+func TestReadMessageClosesOnViolations(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+		code  int
+	}{
+		{
+			name:  "unmasked frame",
+			frame: []byte{0x80 | opText, 2, 'h', 'i'},
+			code:  CloseProtocolError,
+		},
+		{
+			name:  "message too big",
+			frame: clientFrame(true, opText, bytes.Repeat([]byte("a"), 20)),
+			code:  CloseMessageTooBig,
+		},
+		{
+			name:  "invalid UTF-8",
+			frame: clientFrame(true, opText, []byte{0xff, 0xfe}),
+			code:  CloseInvalidData,
+		},
+		{
+			name:  "continuation without a message",
+			frame: clientFrame(true, opContinuation, []byte("x")),
+			code:  CloseProtocolError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, client := pipe(10)
+			defer client.Close()
+
+			go client.Write(tt.frame)
+			closed := make(chan int)
+			go func() {
+				opcode, payload, err := readServerFrame(client)
+				if err != nil || opcode != opClose || len(payload) < 2 {
+					t.Errorf("expected a close frame, got opcode %d %v", opcode, err)
+					closed <- 0
+					return
+				}
+				closed <- int(binary.BigEndian.Uint16(payload))
+			}()
+
+			if _, err := conn.ReadMessage(); err == nil {
+				t.Error("expected an error")
+			}
+			if code := <-closed; code != tt.code {
+				t.Errorf("expected close code %d, got %d", tt.code, code)
+			}
+		})
+	}
+}
+
+func TestReadMessageReturnsPeerClose(t *testing.T) {
+	conn, client := pipe(1024)
+	defer client.Close()
+
+	payload := binary.BigEndian.AppendUint16(nil, CloseGoingAway)
+	go client.Write(clientFrame(true, opClose, append(payload, "bye"...)))
+	go readServerFrame(client)
+
+	_, err := conn.ReadMessage()
+	closeErr := &CloseError{}
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseGoingAway || closeErr.Reason != "bye" {
+		t.Errorf("expected the peer's close, got %v", err)
+	}
+	if err := conn.WriteText([]byte("late")); !errors.Is(err, ErrClosed) {
+		t.Errorf("writing after close must fail with ErrClosed, got %v", err)
+	}
+}
+
+func TestWriteTextLengths(t *testing.T) {
+	for _, size := range []int{0, 125, 126, 70000} {
+		conn, client := pipe(1024)
+
+		data := bytes.Repeat([]byte("a"), size)
+		go conn.WriteText(data)
+
+		reader := bufio.NewReader(client)
+		header := make([]byte, 2)
+		io.ReadFull(reader, header)
+		if header[0] != 0x80|opText || header[1]&0x80 != 0 {
+			t.Errorf("size %d: unexpected header %x", size, header)
+		}
+		length := int(header[1])
+		switch length {
+		case 126:
+			extended := make([]byte, 2)
+			io.ReadFull(reader, extended)
+			length = int(binary.BigEndian.Uint16(extended))
+		case 127:
+			extended := make([]byte, 8)
+			io.ReadFull(reader, extended)
+			length = int(binary.BigEndian.Uint64(extended))
+		}
+		if length != size {
+			t.Errorf("expected length %d, got %d", size, length)
+		}
+		io.ReadFull(reader, make([]byte, length))
+		client.Close()
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	upgraded := make(chan *Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := Upgrade(w, req, 1024, time.Second)
+		if err != nil {
+			return
+		}
+		upgraded <- conn
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("plain requests must be rejected, got %d", resp.StatusCode)
+	}
+
+	client, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	io.WriteString(client, "GET / HTTP/1.1\r\n"+
+		"Host: chirpy\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+
+	reader := bufio.NewReader(client)
+	handshake, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if handshake.StatusCode != http.StatusSwitchingProtocols ||
+		handshake.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected handshake response %d %v", handshake.StatusCode, handshake.Header)
+	}
+
+	conn := <-upgraded
+	defer conn.Close(CloseNormal, "")
+	go client.Write(clientFrame(true, opText, []byte("hi")))
+	if message, err := conn.ReadMessage(); err != nil || string(message) != "hi" {
+		t.Errorf("expected hi, got %q %v", message, err)
+	}
+}
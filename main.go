@@ -1,27 +1,69 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/dmitriy-zverev/chirpy/internal/database"
+	"github.com/dmitriy-zverev/chirpy/internal/events"
 	"github.com/dmitriy-zverev/chirpy/internal/handlers"
+	"github.com/dmitriy-zverev/chirpy/internal/moderation"
+	"github.com/dmitriy-zverev/chirpy/internal/storage"
+	"github.com/dmitriy-zverev/chirpy/internal/timeline"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 // Config holds all configuration values for the application
 type Config struct {
-	DBUrl     string
-	Platform  string
-	JWTSecret []byte
-	PolkaKey  []byte
-	Port      string
+	DBUrl           string
+	Platform        string
+	JWTSecret       []byte
+	PolkaKey        []byte
+	PolkaSecrets    [][]byte
+	PolkaTolerance  time.Duration
+	Port            string
+	FanoutThreshold int64
+	MediaStorage    string
+	MediaDir        string
+	S3Endpoint      string
+	S3Bucket        string
+	S3Region        string
+	S3AccessKey     string
+	S3SecretKey     string
+	ChirpMaxLength  int
+	ChirpMaxRed     int
 }
 
+// Background worker settings
+const (
+	fanoutWorkers     = 4
+	fanoutQueueSize   = 1024
+	schedulerInterval = 15 * time.Second
+
+	pollCloserInterval = 30 * time.Second
+
+	moderationReloadInterval = 30 * time.Second
+
+	trashPurgeInterval = time.Hour
+
+	idempotencyPurgeInterval = time.Hour
+
+	streamReplayBufferSize = 1024
+
+	webhookWorkers          = 4
+	webhookDispatchInterval = 5 * time.Second
+	webhookPurgeInterval    = time.Hour
+
+	subscriptionExpiryInterval = time.Minute
+)
+
 // Route path constants
 const (
 	appPrefix        = "/app/"
@@ -32,11 +74,72 @@ const (
 	resetPath        = adminPrefix + "/reset"
 	chirpsPath       = apiPrefix + "/chirps"
 	chirpPath        = apiPrefix + "/chirps/{chirpID}"
+	chirpsStreamPath = apiPrefix + "/chirps/stream"
+	websocketPath    = apiPrefix + "/ws"
 	usersPath        = apiPrefix + "/users"
 	loginPath        = apiPrefix + "/login"
 	refreshPath      = apiPrefix + "/refresh"
 	revokePath       = apiPrefix + "/revoke"
 	polkaWebhookPath = apiPrefix + "/polka/webhooks"
+	followPath       = apiPrefix + "/users/{userID}/follow"
+	timelinePath     = apiPrefix + "/timeline"
+	blockPath        = apiPrefix + "/users/{userID}/block"
+	mutePath         = apiPrefix + "/users/{userID}/mute"
+
+	preferencesPath = apiPrefix + "/users/preferences"
+
+	chirpsExportPath = apiPrefix + "/users/me/chirps/export"
+	chirpsImportPath = apiPrefix + "/users/me/chirps/import"
+
+	notificationsPath     = apiPrefix + "/notifications"
+	notificationsReadPath = apiPrefix + "/notifications/read"
+
+	conversationsPath = apiPrefix + "/conversations"
+	messagesPath      = apiPrefix + "/conversations/{conversationID}/messages"
+
+	listsPath        = apiPrefix + "/lists"
+	listPath         = apiPrefix + "/lists/{listID}"
+	listMembersPath  = apiPrefix + "/lists/{listID}/members"
+	listMemberPath   = apiPrefix + "/lists/{listID}/members/{userID}"
+	listTimelinePath = apiPrefix + "/lists/{listID}/timeline"
+
+	bookmarkPath        = apiPrefix + "/chirps/{chirpID}/bookmark"
+	bookmarksPath       = apiPrefix + "/bookmarks"
+	bookmarkFoldersPath = apiPrefix + "/bookmarks/folders"
+	bookmarkFolderPath  = apiPrefix + "/bookmarks/folders/{folderID}"
+
+	pinPath = apiPrefix + "/chirps/{chirpID}/pin"
+
+	pollVotePath = apiPrefix + "/chirps/{chirpID}/poll/vote"
+
+	chirpsTrashPath  = apiPrefix + "/chirps/trash"
+	chirpRestorePath = apiPrefix + "/chirps/{chirpID}/restore"
+
+	scheduledChirpsPath = apiPrefix + "/scheduled_chirps"
+	scheduledChirpPath  = apiPrefix + "/scheduled_chirps/{scheduledID}"
+
+	mediaPath           = apiPrefix + "/media"
+	mediumPath          = apiPrefix + "/media/{mediaID}"
+	mediumThumbnailPath = apiPrefix + "/media/{mediaID}/thumbnail"
+
+	moderationRulesPath  = adminPrefix + "/moderation/rules"
+	moderationRulePath   = adminPrefix + "/moderation/rules/{ruleID}"
+	heldChirpsPath       = adminPrefix + "/moderation/held"
+	heldChirpPath        = adminPrefix + "/moderation/held/{heldID}"
+	heldChirpApprovePath = adminPrefix + "/moderation/held/{heldID}/approve"
+
+	webhooksPath             = apiPrefix + "/webhooks"
+	webhookPath              = apiPrefix + "/webhooks/{webhookID}"
+	webhookDeliveriesPath    = apiPrefix + "/webhooks/{webhookID}/deliveries"
+	webhookDeliveryRetryPath = apiPrefix + "/webhooks/{webhookID}/deliveries/{deliveryID}/retry"
+
+	reportPath        = apiPrefix + "/chirps/{chirpID}/report"
+	reportsPath       = adminPrefix + "/moderation/reports"
+	reportResolvePath = adminPrefix + "/moderation/reports/{chirpID}/resolve"
+	suspensionPath    = adminPrefix + "/moderation/suspensions/{userID}"
+
+	polkaEventsPath      = adminPrefix + "/polka/events"
+	polkaEventReplayPath = adminPrefix + "/polka/events/{eventID}/replay"
 )
 
 func main() {
@@ -51,17 +154,59 @@ func main() {
 	}
 	defer db.Close()
 
+	blobs, err := initializeBlobStore(config)
+	if err != nil {
+		log.Fatal("Failed to initialize media storage:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fanout := timeline.NewFanout(dbQueries, config.FanoutThreshold, fanoutQueueSize)
+	fanout.Start(ctx, fanoutWorkers)
+
+	hub := events.NewHub(dbQueries, streamReplayBufferSize)
+	if err := hub.Start(ctx, config.DBUrl); err != nil {
+		log.Fatal("Failed to listen for chirp events:", err)
+	}
+
 	apiConfig := &handlers.ApiConfig{
-		DbQueries: dbQueries,
-		Platform:  config.Platform,
-		JWTSecret: config.JWTSecret,
-		PolkaKey:  config.PolkaKey,
+		DB:         db,
+		DbQueries:  dbQueries,
+		Platform:   config.Platform,
+		JWTSecret:  config.JWTSecret,
+		PolkaKey:   config.PolkaKey,
+		Fanout:     fanout,
+		Blobs:      blobs,
+		Moderation: moderation.NewEngine(),
+		Events:     hub,
+
+		ChirpMaxLength:    config.ChirpMaxLength,
+		ChirpMaxLengthRed: config.ChirpMaxRed,
+
+		PolkaSecrets:            config.PolkaSecrets,
+		PolkaSignatureTolerance: config.PolkaTolerance,
+	}
+
+	if err := apiConfig.LoadModerationRules(ctx); err != nil {
+		log.Println("Warning: failed to load moderation rules:", err)
+	}
+
+	go apiConfig.RunScheduler(ctx, schedulerInterval)
+	go apiConfig.RunPollCloser(ctx, pollCloserInterval)
+	go apiConfig.RunModerationReloader(ctx, moderationReloadInterval)
+	go apiConfig.RunTrashPurger(ctx, trashPurgeInterval)
+	go apiConfig.RunIdempotencyKeyPurger(ctx, idempotencyPurgeInterval)
+	for range webhookWorkers {
+		go apiConfig.RunWebhookDispatcher(ctx, webhookDispatchInterval)
 	}
+	go apiConfig.RunWebhookDeliveryPurger(ctx, webhookPurgeInterval)
+	go apiConfig.RunSubscriptionExpirer(ctx, subscriptionExpiryInterval)
 
 	mux := setupRoutes(apiConfig)
 
 	log.Printf("Serving files from %s on port: %s\n", appPrefix, config.Port)
-	if err := startServer(mux, config.Port); err != nil {
+	if err := startServer(apiConfig.MiddlewareSuspension(mux), config.Port); err != nil {
 		log.Fatal("Server failed to start:", err)
 	}
 }
@@ -87,9 +232,28 @@ func loadConfiguration() (*Config, error) {
 		return nil, fmt.Errorf("JWT_SECRET environment variable is required")
 	}
 
+	// Polka authenticates with the API key, signs its webhooks with the
+	// secret, or both while it moves over. The previous secret stays valid
+	// during a rotation.
 	polkaKey := os.Getenv("POLKA_KEY")
-	if polkaKey == "" {
-		return nil, fmt.Errorf("POLKA_KEY environment variable is required")
+	polkaSecret := os.Getenv("POLKA_WEBHOOK_SECRET")
+	if polkaKey == "" && polkaSecret == "" {
+		return nil, fmt.Errorf("POLKA_KEY or POLKA_WEBHOOK_SECRET environment variable is required")
+	}
+	polkaSecrets := [][]byte{}
+	for _, secret := range []string{polkaSecret, os.Getenv("POLKA_WEBHOOK_SECRET_PREVIOUS")} {
+		if secret != "" {
+			polkaSecrets = append(polkaSecrets, []byte(secret))
+		}
+	}
+
+	polkaTolerance := 5 * time.Minute // default signature timestamp tolerance
+	if value := os.Getenv("POLKA_SIGNATURE_TOLERANCE"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid POLKA_SIGNATURE_TOLERANCE: %s", value)
+		}
+		polkaTolerance = parsed
 	}
 
 	port := os.Getenv("PORT")
@@ -97,12 +261,61 @@ func loadConfiguration() (*Config, error) {
 		port = "8080" // default port
 	}
 
+	fanoutThreshold := int64(10000) // default follower threshold
+	if value := os.Getenv("FANOUT_FOLLOWER_THRESHOLD"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FANOUT_FOLLOWER_THRESHOLD: %w", err)
+		}
+		fanoutThreshold = parsed
+	}
+
+	chirpMaxLength := 140 // default chirp length limit
+	if value := os.Getenv("CHIRP_MAX_LENGTH"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CHIRP_MAX_LENGTH: %w", err)
+		}
+		chirpMaxLength = parsed
+	}
+
+	chirpMaxRed := 280 // default chirp length limit for Chirpy Red
+	if value := os.Getenv("CHIRP_MAX_LENGTH_RED"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CHIRP_MAX_LENGTH_RED: %w", err)
+		}
+		chirpMaxRed = parsed
+	}
+
+	mediaStorage := os.Getenv("MEDIA_STORAGE")
+	if mediaStorage == "" {
+		mediaStorage = "local" // default to the local filesystem
+	}
+
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "./media" // default media directory
+	}
+
 	return &Config{
-		DBUrl:     dbURL,
-		Platform:  platform,
-		JWTSecret: []byte(jwtSecret),
-		PolkaKey:  []byte(polkaKey),
-		Port:      port,
+		DBUrl:           dbURL,
+		Platform:        platform,
+		JWTSecret:       []byte(jwtSecret),
+		PolkaKey:        []byte(polkaKey),
+		PolkaSecrets:    polkaSecrets,
+		PolkaTolerance:  polkaTolerance,
+		Port:            port,
+		FanoutThreshold: fanoutThreshold,
+		MediaStorage:    mediaStorage,
+		MediaDir:        mediaDir,
+		S3Endpoint:      os.Getenv("S3_ENDPOINT"),
+		S3Bucket:        os.Getenv("S3_BUCKET"),
+		S3Region:        os.Getenv("S3_REGION"),
+		S3AccessKey:     os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretKey:     os.Getenv("S3_SECRET_ACCESS_KEY"),
+		ChirpMaxLength:  chirpMaxLength,
+		ChirpMaxRed:     chirpMaxRed,
 	}, nil
 }
 
@@ -123,6 +336,25 @@ func initializeDatabase(dbURL string) (*database.Queries, *sql.DB, error) {
 	return dbQueries, db, nil
 }
 
+// initializeBlobStore picks where uploaded media is kept
+func initializeBlobStore(config *Config) (storage.BlobStore, error) {
+	switch config.MediaStorage {
+	case "local":
+		return storage.NewLocalStore(config.MediaDir)
+	case "s3":
+		if config.S3Endpoint == "" || config.S3Bucket == "" {
+			return nil, fmt.Errorf("S3_ENDPOINT and S3_BUCKET are required for s3 media storage")
+		}
+		region := config.S3Region
+		if region == "" {
+			region = "us-east-1" // default region
+		}
+		return storage.NewS3Store(config.S3Endpoint, config.S3Bucket, region, config.S3AccessKey, config.S3SecretKey)
+	default:
+		return nil, fmt.Errorf("unknown MEDIA_STORAGE %q, expected local or s3", config.MediaStorage)
+	}
+}
+
 // setupRoutes configures all HTTP routes and returns the configured ServeMux
 func setupRoutes(cfg *handlers.ApiConfig) *http.ServeMux {
 	mux := http.NewServeMux()
@@ -143,31 +375,112 @@ func setupRoutes(cfg *handlers.ApiConfig) *http.ServeMux {
 	mux.HandleFunc("GET "+metricsPath, cfg.MetricsHandler().ServeHTTP)
 	mux.HandleFunc("POST "+resetPath, cfg.ResetHandler().ServeHTTP)
 
+	// Moderation routes
+	mux.HandleFunc("GET "+moderationRulesPath, cfg.ModerationRulesGetHandler)
+	mux.HandleFunc("POST "+moderationRulesPath, cfg.ModerationRulesPostHandler)
+	mux.HandleFunc("PUT "+moderationRulePath, cfg.ModerationRulePutHandler)
+	mux.HandleFunc("DELETE "+moderationRulePath, cfg.ModerationRuleDeleteHandler)
+	mux.HandleFunc("GET "+heldChirpsPath, cfg.HeldChirpsGetHandler)
+	mux.HandleFunc("POST "+heldChirpApprovePath, cfg.HeldChirpApproveHandler)
+	mux.HandleFunc("DELETE "+heldChirpPath, cfg.HeldChirpDeleteHandler)
+	mux.HandleFunc("GET "+reportsPath, cfg.ReportsGetHandler)
+	mux.HandleFunc("POST "+reportResolvePath, cfg.ReportResolveHandler)
+	mux.HandleFunc("PUT "+suspensionPath, cfg.SuspensionPutHandler)
+	mux.HandleFunc("DELETE "+suspensionPath, cfg.SuspensionDeleteHandler)
+
 	// User routes
-	mux.HandleFunc("POST "+usersPath, cfg.UsersHandler)
+	mux.Handle("POST "+usersPath, cfg.MiddlewareIdempotency(cfg.EmailIdempotencyScope, http.HandlerFunc(cfg.UsersHandler)))
 	mux.HandleFunc("PUT "+usersPath, cfg.UsersPutHandler)
+	mux.HandleFunc("GET "+preferencesPath, cfg.PreferencesGetHandler)
+	mux.HandleFunc("PUT "+preferencesPath, cfg.PreferencesPutHandler)
 	mux.HandleFunc("POST "+loginPath, cfg.LoginHandler)
+	mux.HandleFunc("POST "+followPath, cfg.FollowHandler)
+	mux.HandleFunc("DELETE "+followPath, cfg.UnfollowHandler)
+	mux.HandleFunc("POST "+blockPath, cfg.BlockHandler)
+	mux.HandleFunc("DELETE "+blockPath, cfg.UnblockHandler)
+	mux.HandleFunc("POST "+mutePath, cfg.MuteHandler)
+	mux.HandleFunc("DELETE "+mutePath, cfg.UnmuteHandler)
 
 	// Token routes
 	mux.HandleFunc("POST "+refreshPath, cfg.RefreshHandler)
 	mux.HandleFunc("POST "+revokePath, cfg.RevokeHandler)
 
 	// Chirp routes
-	mux.HandleFunc("POST "+chirpsPath, cfg.ChirpsPostHandler)
+	mux.Handle("POST "+chirpsPath, cfg.MiddlewareIdempotency(cfg.UserIdempotencyScope, http.HandlerFunc(cfg.ChirpsPostHandler)))
 	mux.HandleFunc("GET "+chirpsPath, cfg.ChirpsGetHandler)
+	mux.HandleFunc("GET "+chirpsStreamPath, cfg.ChirpsStreamHandler)
+	mux.HandleFunc("GET "+websocketPath, cfg.WebSocketHandler)
 	mux.HandleFunc("GET "+chirpPath, cfg.ChirpGetHandler)
 	mux.HandleFunc("DELETE "+chirpPath, cfg.ChirpDeleteHandler)
+	mux.HandleFunc("GET "+chirpsExportPath, cfg.ChirpsExportHandler)
+	mux.HandleFunc("POST "+chirpsImportPath, cfg.ChirpsImportHandler)
+	mux.HandleFunc("GET "+chirpsTrashPath, cfg.ChirpsTrashGetHandler)
+	mux.HandleFunc("POST "+chirpRestorePath, cfg.ChirpRestoreHandler)
+	mux.HandleFunc("PUT "+pinPath, cfg.PinPutHandler)
+	mux.HandleFunc("DELETE "+pinPath, cfg.PinDeleteHandler)
+	mux.HandleFunc("POST "+pollVotePath, cfg.PollVoteHandler)
+	mux.HandleFunc("POST "+reportPath, cfg.ReportPostHandler)
+
+	// Media routes
+	mux.HandleFunc("POST "+mediaPath, cfg.MediaPostHandler)
+	mux.HandleFunc("GET "+mediumPath, cfg.MediaGetHandler)
+	mux.HandleFunc("GET "+mediumThumbnailPath, cfg.MediaThumbnailGetHandler)
+
+	// Draft and scheduled chirp routes
+	mux.HandleFunc("POST "+scheduledChirpsPath, cfg.ScheduledChirpsPostHandler)
+	mux.HandleFunc("GET "+scheduledChirpsPath, cfg.ScheduledChirpsGetHandler)
+	mux.HandleFunc("PUT "+scheduledChirpPath, cfg.ScheduledChirpPutHandler)
+	mux.HandleFunc("DELETE "+scheduledChirpPath, cfg.ScheduledChirpDeleteHandler)
+
+	// Bookmark routes
+	mux.HandleFunc("PUT "+bookmarkPath, cfg.BookmarkPutHandler)
+	mux.HandleFunc("DELETE "+bookmarkPath, cfg.BookmarkDeleteHandler)
+	mux.HandleFunc("GET "+bookmarksPath, cfg.BookmarksGetHandler)
+	mux.HandleFunc("POST "+bookmarkFoldersPath, cfg.BookmarkFoldersPostHandler)
+	mux.HandleFunc("GET "+bookmarkFoldersPath, cfg.BookmarkFoldersGetHandler)
+	mux.HandleFunc("DELETE "+bookmarkFolderPath, cfg.BookmarkFolderDeleteHandler)
+
+	// Timeline routes
+	mux.HandleFunc("GET "+timelinePath, cfg.TimelineHandler)
+
+	// List routes
+	mux.HandleFunc("POST "+listsPath, cfg.ListsPostHandler)
+	mux.HandleFunc("GET "+listsPath, cfg.ListsGetHandler)
+	mux.HandleFunc("GET "+listPath, cfg.ListGetHandler)
+	mux.HandleFunc("PUT "+listPath, cfg.ListPutHandler)
+	mux.HandleFunc("DELETE "+listPath, cfg.ListDeleteHandler)
+	mux.HandleFunc("GET "+listMembersPath, cfg.ListMembersGetHandler)
+	mux.HandleFunc("PUT "+listMemberPath, cfg.ListMemberPutHandler)
+	mux.HandleFunc("DELETE "+listMemberPath, cfg.ListMemberDeleteHandler)
+	mux.HandleFunc("GET "+listTimelinePath, cfg.ListTimelineHandler)
+
+	// Notification routes
+	mux.HandleFunc("GET "+notificationsPath, cfg.NotificationsGetHandler)
+	mux.HandleFunc("POST "+notificationsReadPath, cfg.NotificationsReadHandler)
+
+	// Direct message routes
+	mux.HandleFunc("POST "+conversationsPath, cfg.ConversationsPostHandler)
+	mux.HandleFunc("GET "+conversationsPath, cfg.ConversationsGetHandler)
+	mux.HandleFunc("POST "+messagesPath, cfg.MessagesPostHandler)
+	mux.HandleFunc("GET "+messagesPath, cfg.MessagesGetHandler)
 
 	// Webhook routes
-	mux.HandleFunc("POST "+polkaWebhookPath, cfg.PolkaHookPostHandler)
+	mux.Handle("POST "+polkaWebhookPath, cfg.MiddlewareIdempotency(cfg.PolkaIdempotencyScope, http.HandlerFunc(cfg.PolkaHookPostHandler)))
+	mux.HandleFunc("GET "+polkaEventsPath, cfg.PolkaEventsGetHandler)
+	mux.HandleFunc("POST "+polkaEventReplayPath, cfg.PolkaEventReplayHandler)
+	mux.HandleFunc("POST "+webhooksPath, cfg.WebhooksPostHandler)
+	mux.HandleFunc("GET "+webhooksPath, cfg.WebhooksGetHandler)
+	mux.HandleFunc("DELETE "+webhookPath, cfg.WebhookDeleteHandler)
+	mux.HandleFunc("GET "+webhookDeliveriesPath, cfg.WebhookDeliveriesGetHandler)
+	mux.HandleFunc("POST "+webhookDeliveryRetryPath, cfg.WebhookDeliveryRetryHandler)
 
 	return mux
 }
 
 // startServer creates and starts the HTTP server
-func startServer(mux *http.ServeMux, port string) error {
+func startServer(handler http.Handler, port string) error {
 	server := &http.Server{
-		Handler: mux,
+		Handler: handler,
 		Addr:    ":" + port,
 	}
 
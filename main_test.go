@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/dmitriy-zverev/chirpy/internal/handlers"
+)
+
+// ServeMux panics when two patterns overlap without either being more
+// specific, so building the real mux catches route conflicts
+func TestSetupRoutes(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("registering routes panicked: %v", r)
+		}
+	}()
+
+	setupRoutes(&handlers.ApiConfig{})
+}
@@ -0,0 +1,95 @@
+-- name: UpsertBookmark :exec
+INSERT INTO bookmarks (user_id, chirp_id, folder_id, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, chirp_id)
+DO UPDATE SET folder_id = EXCLUDED.folder_id;
+
+-- name: DeleteBookmark :exec
+DELETE FROM bookmarks
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: TombstoneBookmarks :exec
+UPDATE bookmarks
+SET chirp_deleted_at = NOW()
+WHERE chirp_id = $1 AND chirp_deleted_at IS NULL;
+
+-- name: RestoreBookmarks :exec
+UPDATE bookmarks
+SET chirp_deleted_at = NULL
+WHERE chirp_id = $1;
+
+-- name: GetBookmarks :many
+SELECT
+    bookmarks.chirp_id,
+    bookmarks.folder_id,
+    bookmarks.created_at,
+    bookmarks.chirp_deleted_at,
+    chirps.created_at AS chirp_created_at,
+    chirps.updated_at AS chirp_updated_at,
+    chirps.body AS chirp_body,
+    chirps.user_id AS chirp_user_id,
+    chirps.content_warning AS chirp_content_warning,
+    chirps.sensitive AS chirp_sensitive,
+    chirps.visibility AS chirp_visibility
+FROM bookmarks
+LEFT JOIN chirps
+ON bookmarks.chirp_id = chirps.id
+AND chirps.deleted_at IS NULL
+WHERE bookmarks.user_id = @user_id
+AND bookmarks.created_at < @before
+AND (
+    sqlc.narg(folder_id)::uuid IS NULL
+    OR bookmarks.folder_id = sqlc.narg(folder_id)
+)
+AND (
+    chirps.id IS NULL
+    OR (
+        chirps.user_id NOT IN (
+            SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = @user_id
+            UNION
+            SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = @user_id
+        )
+        AND (
+            chirps.visibility = 'public'
+            OR chirps.user_id = @user_id
+            OR EXISTS (
+                SELECT 1 FROM chirp_mentions
+                WHERE chirp_mentions.chirp_id = chirps.id
+                AND chirp_mentions.user_id = @user_id
+            )
+            OR (
+                chirps.visibility = 'followers'
+                AND EXISTS (
+                    SELECT 1 FROM follows
+                    WHERE follows.follower_id = @user_id
+                    AND follows.followee_id = chirps.user_id
+                )
+            )
+        )
+    )
+)
+ORDER BY bookmarks.created_at DESC
+LIMIT @max_results;
+
+-- name: CreateBookmarkFolder :one
+INSERT INTO bookmark_folders (id, created_at, user_id, name)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: GetBookmarkFolder :one
+SELECT * FROM bookmark_folders
+WHERE id = $1;
+
+-- name: GetBookmarkFolders :many
+SELECT * FROM bookmark_folders
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteBookmarkFolder :exec
+DELETE FROM bookmark_folders
+WHERE id = $1 AND user_id = $2;
\ No newline at end of file
@@ -0,0 +1,15 @@
+-- name: AddChirpMentions :exec
+INSERT INTO chirp_mentions (chirp_id, user_id)
+SELECT @chirp_id::uuid, users.id FROM users
+WHERE users.id = ANY(@user_ids::uuid[])
+AND NOT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocks.blocker_id = users.id AND blocks.blocked_id = @author_id)
+    OR (blocks.blocker_id = @author_id AND blocks.blocked_id = users.id)
+)
+ON CONFLICT DO NOTHING;
+
+-- name: GetMentionsForChirps :many
+SELECT * FROM chirp_mentions
+WHERE chirp_id = ANY(@chirp_ids::uuid[])
+ORDER BY chirp_id, user_id;
\ No newline at end of file
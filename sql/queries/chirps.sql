@@ -1,26 +1,183 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, content_warning, sensitive, visibility)
 VALUES (
     gen_random_uuid(),
+    COALESCE(sqlc.narg(created_at)::timestamp, NOW()),
     NOW(),
-    NOW(),
-    $1,
-    $2
+    @body,
+    @user_id,
+    @content_warning,
+    @sensitive,
+    @visibility
 )
 RETURNING *;
 
 -- name: GetChirps :many
 SELECT * from chirps
+WHERE chirps.deleted_at IS NULL
+AND chirps.user_id NOT IN (
+    SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = @viewer_id
+    UNION
+    SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = @viewer_id
+    UNION
+    SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = @viewer_id
+)
+AND (
+    chirps.visibility = 'public'
+    OR chirps.user_id = @viewer_id
+    OR EXISTS (
+        SELECT 1 FROM chirp_mentions
+        WHERE chirp_mentions.chirp_id = chirps.id
+        AND chirp_mentions.user_id = @viewer_id
+    )
+    OR (
+        chirps.visibility = 'followers'
+        AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = @viewer_id
+            AND follows.followee_id = chirps.user_id
+        )
+    )
+)
 ORDER BY created_at ASC;
 
 -- name: GetChirp :one
 SELECT * from chirps
-WHERE id = $1;
+WHERE id = $1 AND deleted_at IS NULL;
 
--- name: DeleteChirp :exec
-DELETE FROM chirps
-WHERE id = $1;
+-- name: GetVisibleChirp :one
+SELECT * from chirps
+WHERE chirps.id = @id
+AND chirps.deleted_at IS NULL
+AND (
+    chirps.visibility = 'public'
+    OR chirps.user_id = @viewer_id
+    OR EXISTS (
+        SELECT 1 FROM chirp_mentions
+        WHERE chirp_mentions.chirp_id = chirps.id
+        AND chirp_mentions.user_id = @viewer_id
+    )
+    OR (
+        chirps.visibility = 'followers'
+        AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = @viewer_id
+            AND follows.followee_id = chirps.user_id
+        )
+    )
+);
+
+-- name: IsChirpVisible :one
+SELECT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE chirps.id = @id
+    AND chirps.user_id NOT IN (
+        SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = @viewer_id
+        UNION
+        SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = @viewer_id
+        UNION
+        SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = @viewer_id
+    )
+    AND (
+        chirps.visibility = 'public'
+        OR chirps.user_id = @viewer_id
+        OR EXISTS (
+            SELECT 1 FROM chirp_mentions
+            WHERE chirp_mentions.chirp_id = chirps.id
+            AND chirp_mentions.user_id = @viewer_id
+        )
+        OR (
+            chirps.visibility = 'followers'
+            AND EXISTS (
+                SELECT 1 FROM follows
+                WHERE follows.follower_id = @viewer_id
+                AND follows.followee_id = chirps.user_id
+            )
+        )
+    )
+);
+
+-- name: DeleteChirp :execrows
+-- With if_updated_at, only deletes the chirp if it hasn't changed since
+UPDATE chirps
+SET deleted_at = NOW(), deleted_by = @deleted_by, updated_at = NOW()
+WHERE id = @id AND deleted_at IS NULL
+AND (sqlc.narg(if_updated_at)::timestamp IS NULL OR updated_at = sqlc.narg(if_updated_at));
+
+-- name: TouchChirp :one
+UPDATE chirps
+SET updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: GetChirpsLastModified :one
+-- Poll votes don't touch their chirp, so they count separately
+SELECT GREATEST(
+    COALESCE((SELECT MAX(updated_at) FROM chirps), '1970-01-01'),
+    COALESCE((SELECT MAX(created_at) FROM poll_votes), '1970-01-01')
+)::timestamp AS last_modified;
 
 -- name: GetChirpsFromId :many
 SELECT * FROM chirps
-WHERE user_id = $1;
\ No newline at end of file
+WHERE user_id = @user_id
+AND chirps.deleted_at IS NULL
+AND chirps.user_id NOT IN (
+    SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = @viewer_id
+    UNION
+    SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = @viewer_id
+    UNION
+    SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = @viewer_id
+)
+AND (
+    chirps.visibility = 'public'
+    OR chirps.user_id = @viewer_id
+    OR EXISTS (
+        SELECT 1 FROM chirp_mentions
+        WHERE chirp_mentions.chirp_id = chirps.id
+        AND chirp_mentions.user_id = @viewer_id
+    )
+    OR (
+        chirps.visibility = 'followers'
+        AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = @viewer_id
+            AND follows.followee_id = chirps.user_id
+        )
+    )
+);
+
+-- name: GetUserChirpsAfter :many
+SELECT * FROM chirps
+WHERE user_id = @user_id
+AND deleted_at IS NULL
+AND (created_at, id) > (@after_created_at::timestamp, @after_id::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT @max_results;
+
+-- name: ChirpExists :one
+-- Whether the user already has this chirp, trashed ones included, for
+-- imports to skip
+SELECT EXISTS (
+    SELECT 1 FROM chirps
+    WHERE user_id = $1 AND created_at = $2 AND body = $3
+);
+
+-- name: GetTrashedChirps :many
+SELECT * FROM chirps
+WHERE user_id = @user_id
+AND deleted_by = @user_id
+AND deleted_at > @deleted_after::timestamp
+ORDER BY deleted_at DESC;
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, deleted_by = NULL, updated_at = NOW()
+WHERE id = @id
+AND user_id = @user_id
+AND deleted_by = @user_id
+AND deleted_at > @deleted_after::timestamp
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at <= @purge_before::timestamp;
\ No newline at end of file
@@ -0,0 +1,72 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, created_by)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1
+)
+RETURNING *;
+
+-- name: AddConversationParticipant :exec
+INSERT INTO conversation_participants (conversation_id, user_id, joined_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: GetDirectConversation :one
+SELECT * FROM conversations
+WHERE id IN (
+    SELECT conversation_id FROM conversation_participants
+    GROUP BY conversation_id
+    HAVING COUNT(*) = 2
+    AND BOOL_OR(user_id = @user_id)
+    AND BOOL_OR(user_id = @other_user_id)
+)
+LIMIT 1;
+
+-- name: IsConversationParticipant :one
+SELECT EXISTS (
+    SELECT 1 FROM conversation_participants
+    WHERE conversation_id = $1 AND user_id = $2
+);
+
+-- name: GetConversationParticipants :many
+SELECT user_id FROM conversation_participants
+WHERE conversation_id = $1;
+
+-- name: CountConversationsCreatedSince :one
+SELECT COUNT(*) FROM conversations
+WHERE created_by = $1 AND created_at > $2;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetConversations :many
+SELECT
+    conversations.id,
+    conversations.created_at,
+    conversations.updated_at,
+    ARRAY(
+        SELECT cp.user_id FROM conversation_participants AS cp
+        WHERE cp.conversation_id = conversations.id
+    )::uuid[] AS participant_ids,
+    last_message.id AS last_message_id,
+    last_message.sender_id AS last_message_sender_id,
+    last_message.body AS last_message_body,
+    last_message.created_at AS last_message_created_at
+FROM conversations
+INNER JOIN conversation_participants
+ON conversations.id = conversation_participants.conversation_id
+LEFT JOIN LATERAL (
+    SELECT messages.id, messages.sender_id, messages.body, messages.created_at
+    FROM messages
+    WHERE messages.conversation_id = conversations.id
+    ORDER BY messages.created_at DESC
+    LIMIT 1
+) AS last_message ON TRUE
+WHERE conversation_participants.user_id = @user_id
+AND conversations.updated_at < @before
+ORDER BY conversations.updated_at DESC
+LIMIT @max_results;
\ No newline at end of file
@@ -0,0 +1,22 @@
+-- name: FollowUser :one
+INSERT INTO follows (follower_id, followee_id, created_at, fanout_on_read)
+SELECT @follower_id::uuid, @followee_id::uuid, NOW(), COUNT(*) >= @fanout_threshold::bigint
+FROM follows
+WHERE follows.followee_id = @followee_id
+ON CONFLICT DO NOTHING
+RETURNING fanout_on_read;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: IsFollowing :one
+SELECT EXISTS (
+    SELECT 1 FROM follows
+    WHERE follower_id = $1 AND followee_id = $2
+);
+
+-- name: RemoveFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = @user_id AND followee_id = @other_user_id)
+OR (follower_id = @other_user_id AND followee_id = @user_id);
\ No newline at end of file
@@ -0,0 +1,35 @@
+-- name: CreateHeldChirp :one
+INSERT INTO held_chirps (id, created_at, user_id, body, payload)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: GetHeldChirps :many
+SELECT * FROM held_chirps
+ORDER BY created_at ASC;
+
+-- name: HeldChirpExists :one
+-- Whether an import already held this chirp for review, for retries to
+-- skip
+SELECT EXISTS (
+    SELECT 1 FROM held_chirps
+    WHERE user_id = $1
+    AND body = $2
+    AND (payload->>'created_at')::timestamp = $3
+);
+
+-- name: ClaimHeldChirp :one
+-- Approvals claim the held chirp by deleting it, so a concurrent approval
+-- waits and then finds nothing
+DELETE FROM held_chirps
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteHeldChirp :execrows
+DELETE FROM held_chirps
+WHERE id = $1;
\ No newline at end of file
@@ -0,0 +1,33 @@
+-- name: ClaimIdempotencyKey :execrows
+-- Takes over expired keys, and claims whose lease ran out before their
+-- request completed
+INSERT INTO idempotency_keys (scope, key, created_at, request_hash, locked_until)
+VALUES (@scope, @key, NOW(), @request_hash, @lease_until)
+ON CONFLICT (scope, key) DO UPDATE
+SET created_at = NOW(),
+    request_hash = EXCLUDED.request_hash,
+    status_code = NULL,
+    content_type = '',
+    response_body = NULL,
+    locked_until = EXCLUDED.locked_until
+WHERE idempotency_keys.created_at <= @expired_before::timestamp
+OR (idempotency_keys.status_code IS NULL AND idempotency_keys.locked_until <= NOW());
+
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE scope = $1 AND key = $2;
+
+-- name: CompleteIdempotencyKey :exec
+-- Claims are matched by their lease too, so a request that outlived its
+-- lease doesn't overwrite the retry that took the key over
+UPDATE idempotency_keys
+SET status_code = $3, content_type = $4, response_body = $5
+WHERE scope = $1 AND key = $2 AND locked_until = $6;
+
+-- name: ReleaseIdempotencyKey :exec
+DELETE FROM idempotency_keys
+WHERE scope = $1 AND key = $2 AND locked_until = $3;
+
+-- name: PurgeIdempotencyKeys :execrows
+DELETE FROM idempotency_keys
+WHERE created_at <= @expired_before::timestamp;
\ No newline at end of file
@@ -0,0 +1,82 @@
+-- name: CreateList :one
+INSERT INTO lists (id, created_at, updated_at, owner_id, name, is_private)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: GetList :one
+SELECT * FROM lists
+WHERE id = $1;
+
+-- name: GetListsByOwner :many
+SELECT * FROM lists
+WHERE owner_id = $1
+ORDER BY created_at ASC;
+
+-- name: UpdateList :one
+UPDATE lists
+SET name = $2, is_private = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteList :exec
+DELETE FROM lists
+WHERE id = $1;
+
+-- name: AddListMember :exec
+INSERT INTO list_members (list_id, user_id, added_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveListMember :exec
+DELETE FROM list_members
+WHERE list_id = $1 AND user_id = $2;
+
+-- name: CountListMembers :one
+SELECT COUNT(*) FROM list_members
+WHERE list_id = $1;
+
+-- name: GetListMembers :many
+SELECT user_id FROM list_members
+WHERE list_id = $1
+ORDER BY added_at ASC;
+
+-- name: GetListTimeline :many
+SELECT chirps.* FROM chirps
+INNER JOIN list_members
+ON chirps.user_id = list_members.user_id
+WHERE list_members.list_id = @list_id
+AND chirps.created_at < @before
+AND chirps.deleted_at IS NULL
+AND chirps.user_id NOT IN (
+    SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = @viewer_id
+    UNION
+    SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = @viewer_id
+    UNION
+    SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = @viewer_id
+)
+AND (
+    chirps.visibility = 'public'
+    OR chirps.user_id = @viewer_id
+    OR EXISTS (
+        SELECT 1 FROM chirp_mentions
+        WHERE chirp_mentions.chirp_id = chirps.id
+        AND chirp_mentions.user_id = @viewer_id
+    )
+    OR (
+        chirps.visibility = 'followers'
+        AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = @viewer_id
+            AND follows.followee_id = chirps.user_id
+        )
+    )
+)
+ORDER BY chirps.created_at DESC
+LIMIT @max_results;
\ No newline at end of file
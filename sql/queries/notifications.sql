@@ -0,0 +1,88 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, created_at, user_id, actor_id, kind, chirp_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: CreateMentionNotifications :many
+INSERT INTO notifications (id, created_at, user_id, actor_id, kind, chirp_id)
+SELECT gen_random_uuid(), NOW(), chirp_mentions.user_id, @actor_id::uuid, 'mention', chirp_mentions.chirp_id
+FROM chirp_mentions
+WHERE chirp_mentions.chirp_id = @chirp_id
+AND chirp_mentions.user_id <> @actor_id
+AND NOT EXISTS (
+    SELECT 1 FROM mutes
+    WHERE mutes.muter_id = chirp_mentions.user_id
+    AND mutes.muted_id = @actor_id
+)
+RETURNING *;
+
+-- name: GetNotification :one
+SELECT * FROM notifications
+WHERE id = @id
+AND user_id = @user_id
+AND (
+    actor_id IS NULL
+    OR actor_id NOT IN (
+        SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = @user_id
+        UNION
+        SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = @user_id
+        UNION
+        SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = @user_id
+    )
+);
+
+-- name: GetNotificationGroups :many
+SELECT
+    kind,
+    chirp_id,
+    COUNT(DISTINCT actor_id) AS actors,
+    COUNT(*) FILTER (WHERE read_at IS NULL) AS unread,
+    (ARRAY_AGG(actor_id ORDER BY created_at DESC))[1]::uuid AS latest_actor_id,
+    MIN(created_at)::timestamp AS started_at,
+    MAX(created_at)::timestamp AS latest_at
+FROM notifications
+WHERE user_id = @user_id
+AND created_at < DATE_TRUNC('day', @before::timestamp) + INTERVAL '1 day'
+AND (
+    actor_id IS NULL
+    OR actor_id NOT IN (
+        SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = @user_id
+        UNION
+        SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = @user_id
+        UNION
+        SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = @user_id
+    )
+)
+GROUP BY kind, chirp_id, DATE_TRUNC('day', created_at)
+HAVING MIN(created_at) < @before
+ORDER BY started_at DESC
+LIMIT @max_results;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = @user_id
+AND read_at IS NULL
+AND (
+    actor_id IS NULL
+    OR actor_id NOT IN (
+        SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = @user_id
+        UNION
+        SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = @user_id
+        UNION
+        SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = @user_id
+    )
+);
+
+-- name: MarkNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1
+AND read_at IS NULL
+AND created_at <= $2;
\ No newline at end of file
@@ -0,0 +1,15 @@
+-- name: PinChirp :exec
+INSERT INTO pinned_chirps (user_id, chirp_id, pinned_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnpinChirp :exec
+DELETE FROM pinned_chirps
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetPinnedChirpIDs :many
+SELECT pinned_chirps.chirp_id FROM pinned_chirps
+INNER JOIN chirps ON chirps.id = pinned_chirps.chirp_id
+WHERE pinned_chirps.user_id = $1
+AND chirps.deleted_at IS NULL
+ORDER BY pinned_chirps.pinned_at DESC;
\ No newline at end of file
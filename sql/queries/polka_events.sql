@@ -0,0 +1,30 @@
+-- name: RecordPolkaEvent :one
+INSERT INTO polka_events (id, received_at, updated_at, event, payload)
+VALUES (@id, NOW(), NOW(), @event, @payload)
+ON CONFLICT (id) DO UPDATE
+SET status = 'processing', attempts = polka_events.attempts + 1, error = NULL, updated_at = NOW()
+WHERE polka_events.status = 'failed'
+OR (polka_events.status = 'processing' AND polka_events.updated_at < @stale_before::timestamp)
+RETURNING *;
+
+-- name: GetPolkaEvents :many
+SELECT * FROM polka_events
+WHERE (@status::text = '' OR status = @status)
+AND received_at < @before
+ORDER BY received_at DESC
+LIMIT @max_results;
+
+-- name: ReplayPolkaEvent :one
+UPDATE polka_events
+SET status = 'processing', attempts = attempts + 1, error = NULL, updated_at = NOW()
+WHERE id = $1 AND status = 'failed'
+RETURNING *;
+
+-- name: FinishPolkaEvent :one
+UPDATE polka_events
+SET status = @status,
+    error = @error,
+    updated_at = NOW(),
+    processed_at = CASE WHEN @status::text = 'processed' THEN NOW() END
+WHERE id = @id
+RETURNING *;
\ No newline at end of file
@@ -0,0 +1,76 @@
+-- name: CreatePoll :one
+INSERT INTO polls (id, created_at, chirp_id, expires_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: CreatePollOption :exec
+INSERT INTO poll_options (id, poll_id, position, text)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3
+);
+
+-- name: GetPollByChirp :one
+SELECT * FROM polls
+WHERE chirp_id = $1;
+
+-- name: GetPollOption :one
+SELECT * FROM poll_options
+WHERE id = $1 AND poll_id = $2;
+
+-- name: CastPollVote :execrows
+INSERT INTO poll_votes (poll_id, user_id, option_id, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: GetPollsForChirps :many
+SELECT * FROM polls
+WHERE chirp_id = ANY(@chirp_ids::uuid[]);
+
+-- name: GetPollResults :many
+SELECT
+    poll_options.id,
+    poll_options.poll_id,
+    poll_options.text,
+    COUNT(poll_votes.user_id) AS votes
+FROM poll_options
+LEFT JOIN poll_votes ON poll_votes.option_id = poll_options.id
+WHERE poll_options.poll_id = ANY(@poll_ids::uuid[])
+GROUP BY poll_options.id
+ORDER BY poll_options.poll_id, poll_options.position ASC;
+
+-- name: GetPollVersions :many
+-- What a poll's results change with, for validators: votes don't touch
+-- the chirp
+SELECT
+    polls.chirp_id,
+    polls.expires_at,
+    COUNT(poll_votes.user_id) AS votes,
+    COALESCE(MAX(poll_votes.created_at), polls.created_at)::timestamp AS last_vote_at
+FROM polls
+LEFT JOIN poll_votes ON poll_votes.poll_id = polls.id
+WHERE polls.chirp_id = ANY(@chirp_ids::uuid[])
+GROUP BY polls.id;
+
+-- name: GetUserPollVotes :many
+SELECT poll_id, option_id FROM poll_votes
+WHERE user_id = @user_id AND poll_id = ANY(@poll_ids::uuid[]);
+
+-- name: ClaimClosedPoll :one
+UPDATE polls
+SET closed_notified_at = NOW()
+WHERE id = (
+    SELECT id FROM polls
+    WHERE expires_at <= NOW() AND closed_notified_at IS NULL
+    ORDER BY expires_at ASC
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
\ No newline at end of file
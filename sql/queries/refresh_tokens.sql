@@ -29,3 +29,9 @@ UPDATE refresh_tokens
 SET revoked_at = $2, updated_at = $2
 WHERE token = $1;
 
+
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,32 @@
+-- name: CreateReport :execrows
+INSERT INTO reports (id, created_at, reporter_id, chirp_id, reason, details)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT DO NOTHING;
+
+-- name: GetOpenReports :many
+SELECT
+    reports.id,
+    reports.created_at,
+    reports.reporter_id,
+    reports.reason,
+    reports.details,
+    chirps.id AS chirp_id,
+    chirps.body AS chirp_body,
+    chirps.user_id AS chirp_user_id
+FROM reports
+INNER JOIN chirps ON chirps.id = reports.chirp_id
+WHERE reports.status = 'open'
+AND chirps.deleted_at IS NULL
+ORDER BY reports.created_at ASC;
+
+-- name: ResolveReports :execrows
+UPDATE reports
+SET status = $2, resolved_at = NOW(), resolved_by = $3
+WHERE chirp_id = $1 AND status = 'open';
\ No newline at end of file
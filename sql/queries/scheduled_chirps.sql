@@ -0,0 +1,50 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, created_at, updated_at, user_id, body, status, publish_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetScheduledChirp :one
+SELECT * FROM scheduled_chirps
+WHERE id = $1;
+
+-- name: GetScheduledChirps :many
+SELECT * FROM scheduled_chirps
+WHERE user_id = $1 AND status <> 'published'
+ORDER BY created_at ASC;
+
+-- name: UpdateScheduledChirp :one
+-- Published chirps are left alone, even when the scheduler got to them
+-- after the caller checked
+UPDATE scheduled_chirps
+SET body = $2, status = $3, publish_at = $4, attempts = 0, last_error = NULL, updated_at = NOW()
+WHERE id = $1 AND status <> 'published'
+RETURNING *;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps
+WHERE id = $1 AND status <> 'published';
+
+-- name: ClaimDueScheduledChirp :one
+SELECT * FROM scheduled_chirps
+WHERE status = 'scheduled' AND publish_at <= NOW()
+ORDER BY publish_at ASC
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkScheduledChirpPublished :exec
+UPDATE scheduled_chirps
+SET status = 'published', chirp_id = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: RecordScheduledChirpFailure :exec
+UPDATE scheduled_chirps
+SET status = $2, publish_at = $3, attempts = attempts + 1, last_error = $4, updated_at = NOW()
+WHERE id = $1;
\ No newline at end of file
@@ -0,0 +1,50 @@
+-- name: GetSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: ActivateSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    @user_id,
+    @plan,
+    'active',
+    @current_period_end
+)
+ON CONFLICT (user_id) DO UPDATE
+SET plan = EXCLUDED.plan,
+    status = 'active',
+    current_period_end = EXCLUDED.current_period_end,
+    canceled_at = NULL,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: CancelSubscription :one
+UPDATE subscriptions
+SET status = CASE WHEN status = 'expired' THEN status ELSE 'canceled' END,
+    canceled_at = COALESCE(canceled_at, NOW()),
+    updated_at = NOW()
+WHERE user_id = $1
+RETURNING *;
+
+-- name: MarkSubscriptionPastDue :one
+UPDATE subscriptions
+SET status = CASE WHEN status = 'active' THEN 'past_due' ELSE status END,
+    updated_at = NOW()
+WHERE user_id = $1
+RETURNING *;
+
+-- name: IsChirpyRed :one
+SELECT EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE user_id = $1
+    AND status <> 'expired'
+    AND current_period_end > NOW()
+);
+
+-- name: ExpireSubscriptions :execrows
+UPDATE subscriptions
+SET status = 'expired', updated_at = NOW()
+WHERE status <> 'expired' AND current_period_end <= NOW();
\ No newline at end of file
@@ -0,0 +1,128 @@
+-- name: FanOutChirp :exec
+INSERT INTO timeline_entries (user_id, chirp_id, created_at)
+SELECT follows.follower_id, @chirp_id::uuid, @created_at::timestamp
+FROM follows
+WHERE follows.followee_id = @author_id
+AND NOT follows.fanout_on_read
+ON CONFLICT DO NOTHING;
+
+-- name: BackfillTimeline :exec
+INSERT INTO timeline_entries (user_id, chirp_id, created_at)
+SELECT @follower_id::uuid, chirps.id, chirps.created_at
+FROM chirps
+WHERE chirps.user_id = @followee_id
+AND chirps.deleted_at IS NULL
+ORDER BY chirps.created_at DESC
+LIMIT @max_entries
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveTimelineEntriesFromAuthor :exec
+DELETE FROM timeline_entries
+WHERE timeline_entries.user_id = @follower_id
+AND timeline_entries.chirp_id IN (
+    SELECT chirps.id FROM chirps
+    WHERE chirps.user_id = @followee_id
+);
+
+-- name: GetTimelineMaterialized :many
+SELECT chirps.* FROM (
+    (
+        SELECT chirps.* FROM timeline_entries
+        INNER JOIN chirps ON chirps.id = timeline_entries.chirp_id
+        WHERE timeline_entries.user_id = @user_id
+        AND timeline_entries.created_at < @before
+        AND chirps.deleted_at IS NULL
+        AND chirps.user_id NOT IN (
+            SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = @user_id
+            UNION
+            SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = @user_id
+            UNION
+            SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = @user_id
+        )
+        AND (
+            chirps.visibility <> 'mentioned'
+            OR EXISTS (
+                SELECT 1 FROM chirp_mentions
+                WHERE chirp_mentions.chirp_id = chirps.id
+                AND chirp_mentions.user_id = @user_id
+            )
+        )
+        ORDER BY timeline_entries.created_at DESC
+        LIMIT @max_results
+    )
+    UNION ALL
+    (
+        SELECT chirps.* FROM chirps
+        WHERE chirps.user_id = @user_id
+        AND chirps.created_at < @before
+        AND chirps.deleted_at IS NULL
+        ORDER BY chirps.created_at DESC
+        LIMIT @max_results
+    )
+    UNION ALL
+    (
+        SELECT chirps.* FROM follows
+        INNER JOIN chirps ON chirps.user_id = follows.followee_id
+        WHERE follows.follower_id = @user_id
+        AND follows.fanout_on_read
+        AND chirps.created_at < @before
+        AND chirps.deleted_at IS NULL
+        AND chirps.user_id NOT IN (
+            SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = @user_id
+            UNION
+            SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = @user_id
+            UNION
+            SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = @user_id
+        )
+        AND (
+            chirps.visibility <> 'mentioned'
+            OR EXISTS (
+                SELECT 1 FROM chirp_mentions
+                WHERE chirp_mentions.chirp_id = chirps.id
+                AND chirp_mentions.user_id = @user_id
+            )
+        )
+        ORDER BY chirps.created_at DESC
+        LIMIT @max_results
+    )
+) AS chirps
+ORDER BY chirps.created_at DESC
+LIMIT @max_results;
+
+-- name: GetTimelineFanoutRead :many
+SELECT chirps.* FROM chirps
+WHERE chirps.created_at < @before
+AND chirps.deleted_at IS NULL
+AND (
+    chirps.user_id = @user_id
+    OR chirps.user_id IN (
+        SELECT follows.followee_id FROM follows
+        WHERE follows.follower_id = @user_id
+    )
+)
+AND chirps.user_id NOT IN (
+    SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = @user_id
+    UNION
+    SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = @user_id
+    UNION
+    SELECT mutes.muted_id FROM mutes WHERE mutes.muter_id = @user_id
+)
+AND (
+    chirps.visibility = 'public'
+    OR chirps.user_id = @user_id
+    OR EXISTS (
+        SELECT 1 FROM chirp_mentions
+        WHERE chirp_mentions.chirp_id = chirps.id
+        AND chirp_mentions.user_id = @user_id
+    )
+    OR (
+        chirps.visibility = 'followers'
+        AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = @user_id
+            AND follows.followee_id = chirps.user_id
+        )
+    )
+)
+ORDER BY chirps.created_at DESC
+LIMIT @max_results;
\ No newline at end of file
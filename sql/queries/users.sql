@@ -25,7 +25,28 @@ UPDATE users
 SET email = $2, hashed_password = $3, updated_at = NOW()
 WHERE id = $1;
 
--- name: UpgradeChirpyRed :exec
+-- name: SetExpandSensitive :exec
 UPDATE users
-SET is_chirpy_red = TRUE
-WHERE id = $1;
\ No newline at end of file
+SET expand_sensitive = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = $1;
+
+-- name: SuspendUser :exec
+UPDATE users
+SET suspended_at = NOW()
+WHERE id = $1 AND suspended_at IS NULL;
+
+-- name: UnsuspendUser :execrows
+UPDATE users
+SET suspended_at = NULL
+WHERE id = $1 AND suspended_at IS NOT NULL;
+
+-- name: LockUser :exec
+-- Serializes a user's limit checks with their inserts. NO KEY UPDATE
+-- doesn't hold up inserts that only reference the user.
+SELECT id FROM users
+WHERE id = $1
+FOR NO KEY UPDATE;
\ No newline at end of file
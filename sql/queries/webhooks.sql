@@ -0,0 +1,77 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (id, created_at, updated_at, user_id, url, secret, events)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    @user_id,
+    @url,
+    @secret,
+    @events::text[]
+)
+RETURNING *;
+
+-- name: GetWebhookSubscription :one
+SELECT * FROM webhook_subscriptions
+WHERE id = $1;
+
+-- name: GetWebhookSubscriptions :many
+SELECT * FROM webhook_subscriptions
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: CountWebhookSubscriptions :one
+SELECT COUNT(*) FROM webhook_subscriptions
+WHERE user_id = $1;
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE FROM webhook_subscriptions
+WHERE id = $1 AND user_id = $2;
+
+-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, created_at, subscription_id, event_id, event, payload, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), webhook_subscriptions.id, @event_id, @event, @payload, NOW()
+FROM webhook_subscriptions
+JOIN users ON users.id = webhook_subscriptions.user_id
+WHERE @event::text = ANY(webhook_subscriptions.events)
+AND (users.is_admin OR webhook_subscriptions.user_id = @subject_id);
+
+-- name: ClaimWebhookDelivery :one
+UPDATE webhook_deliveries
+SET attempts = attempts + 1, next_attempt_at = @lease_until, last_attempt_at = NOW()
+WHERE id = (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at ASC
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered', delivered_at = NOW(), last_status_code = $2, last_error = NULL
+WHERE id = $1;
+
+-- name: RecordWebhookFailure :exec
+UPDATE webhook_deliveries
+SET status = $2, next_attempt_at = $3, last_status_code = $4, last_error = $5
+WHERE id = $1;
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE subscription_id = @subscription_id
+AND (@status::text = '' OR status = @status)
+AND created_at < @before
+ORDER BY created_at DESC
+LIMIT @max_results;
+
+-- name: RetryWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+WHERE id = $1 AND subscription_id = $2 AND status = 'dead'
+RETURNING *;
+
+-- name: PurgeWebhookDeliveries :execrows
+DELETE FROM webhook_deliveries
+WHERE status = 'delivered' AND delivered_at < @delivered_before::timestamp;
\ No newline at end of file
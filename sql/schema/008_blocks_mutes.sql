@@ -0,0 +1,42 @@
+-- +goose Up
+CREATE TABLE blocks (
+    blocker_id UUID NOT NULL,
+    blocked_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (blocker_id, blocked_id),
+
+    CONSTRAINT fk_blocker_id
+    FOREIGN KEY (blocker_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE,
+
+    CONSTRAINT fk_blocked_id
+    FOREIGN KEY (blocked_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX blocks_blocked_id_idx ON blocks (blocked_id);
+
+CREATE TABLE mutes (
+    muter_id UUID NOT NULL,
+    muted_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (muter_id, muted_id),
+
+    CONSTRAINT fk_muter_id
+    FOREIGN KEY (muter_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE,
+
+    CONSTRAINT fk_muted_id
+    FOREIGN KEY (muted_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE mutes;
+DROP TABLE blocks;
\ No newline at end of file
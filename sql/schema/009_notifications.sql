@@ -0,0 +1,31 @@
+-- +goose Up
+CREATE TABLE notifications (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    actor_id UUID,
+    kind TEXT NOT NULL,
+    chirp_id UUID,
+    read_at TIMESTAMP,
+
+    CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE,
+
+    CONSTRAINT fk_actor_id
+    FOREIGN KEY (actor_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE,
+
+    CONSTRAINT fk_chirp_id
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps (id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX notifications_user_id_created_at_idx
+ON notifications (user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE notifications;
\ No newline at end of file
@@ -0,0 +1,62 @@
+-- +goose Up
+CREATE TABLE conversations (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_by UUID NOT NULL,
+
+    CONSTRAINT fk_created_by
+    FOREIGN KEY (created_by)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX conversations_created_by_created_at_idx
+ON conversations (created_by, created_at);
+
+CREATE TABLE conversation_participants (
+    conversation_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    joined_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (conversation_id, user_id),
+
+    CONSTRAINT fk_conversation_id
+    FOREIGN KEY (conversation_id)
+    REFERENCES conversations (id)
+    ON DELETE CASCADE,
+
+    CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX conversation_participants_user_id_idx
+ON conversation_participants (user_id);
+
+CREATE TABLE messages (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    conversation_id UUID NOT NULL,
+    sender_id UUID NOT NULL,
+    body TEXT NOT NULL,
+
+    CONSTRAINT fk_conversation_id
+    FOREIGN KEY (conversation_id)
+    REFERENCES conversations (id)
+    ON DELETE CASCADE,
+
+    CONSTRAINT fk_sender_id
+    FOREIGN KEY (sender_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX messages_conversation_id_created_at_idx
+ON messages (conversation_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE messages;
+DROP TABLE conversation_participants;
+DROP TABLE conversations;
\ No newline at end of file
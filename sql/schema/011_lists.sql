@@ -0,0 +1,38 @@
+-- +goose Up
+CREATE TABLE lists (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    owner_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    is_private BOOLEAN NOT NULL DEFAULT FALSE,
+
+    CONSTRAINT fk_owner_id
+    FOREIGN KEY (owner_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX lists_owner_id_idx ON lists (owner_id);
+
+CREATE TABLE list_members (
+    list_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    added_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (list_id, user_id),
+
+    CONSTRAINT fk_list_id
+    FOREIGN KEY (list_id)
+    REFERENCES lists (id)
+    ON DELETE CASCADE,
+
+    CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE list_members;
+DROP TABLE lists;
\ No newline at end of file
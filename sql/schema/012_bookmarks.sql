@@ -0,0 +1,43 @@
+-- +goose Up
+CREATE TABLE bookmark_folders (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    name TEXT NOT NULL,
+
+    CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+);
+
+-- chirp_id deliberately has no foreign key: bookmarks of deleted chirps
+-- stay behind as tombstones
+CREATE TABLE bookmarks (
+    user_id UUID NOT NULL,
+    chirp_id UUID NOT NULL,
+    folder_id UUID,
+    created_at TIMESTAMP NOT NULL,
+    chirp_deleted_at TIMESTAMP,
+
+    PRIMARY KEY (user_id, chirp_id),
+
+    CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE,
+
+    CONSTRAINT fk_folder_id
+    FOREIGN KEY (folder_id)
+    REFERENCES bookmark_folders (id)
+    ON DELETE SET NULL
+);
+
+CREATE INDEX bookmarks_user_id_created_at_idx
+ON bookmarks (user_id, created_at DESC);
+
+CREATE INDEX bookmarks_chirp_id_idx ON bookmarks (chirp_id);
+
+-- +goose Down
+DROP TABLE bookmarks;
+DROP TABLE bookmark_folders;
\ No newline at end of file
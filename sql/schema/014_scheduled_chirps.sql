@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE scheduled_chirps (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    body TEXT NOT NULL,
+    status TEXT NOT NULL,
+    publish_at TIMESTAMP,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    chirp_id UUID,
+
+    CONSTRAINT scheduled_chirps_status_check
+    CHECK (status IN ('draft', 'scheduled', 'published', 'failed')),
+
+    CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE,
+
+    CONSTRAINT fk_chirp_id
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps (id)
+    ON DELETE SET NULL
+);
+
+CREATE INDEX scheduled_chirps_user_id_idx ON scheduled_chirps (user_id);
+
+CREATE INDEX scheduled_chirps_due_idx
+ON scheduled_chirps (publish_at)
+WHERE status = 'scheduled';
+
+-- +goose Down
+DROP TABLE scheduled_chirps;
\ No newline at end of file
@@ -0,0 +1,63 @@
+-- +goose Up
+CREATE TABLE polls (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    closed_notified_at TIMESTAMP,
+
+    CONSTRAINT fk_chirp_id
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps (id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX polls_unnotified_expires_at_idx
+ON polls (expires_at)
+WHERE closed_notified_at IS NULL;
+
+CREATE TABLE poll_options (
+    id UUID PRIMARY KEY,
+    poll_id UUID NOT NULL,
+    position INTEGER NOT NULL,
+    text TEXT NOT NULL,
+
+    CONSTRAINT fk_poll_id
+    FOREIGN KEY (poll_id)
+    REFERENCES polls (id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX poll_options_poll_id_idx ON poll_options (poll_id);
+
+-- The primary key is what limits everyone to one vote per poll
+CREATE TABLE poll_votes (
+    poll_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    option_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (poll_id, user_id),
+
+    CONSTRAINT fk_poll_id
+    FOREIGN KEY (poll_id)
+    REFERENCES polls (id)
+    ON DELETE CASCADE,
+
+    CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE,
+
+    CONSTRAINT fk_option_id
+    FOREIGN KEY (option_id)
+    REFERENCES poll_options (id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX poll_votes_option_id_idx ON poll_votes (option_id);
+
+-- +goose Down
+DROP TABLE poll_votes;
+DROP TABLE poll_options;
+DROP TABLE polls;
\ No newline at end of file
@@ -0,0 +1,48 @@
+-- +goose Up
+ALTER TABLE users
+ADD is_admin BOOLEAN NOT NULL
+DEFAULT FALSE;
+
+CREATE TABLE moderation_rules (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    kind TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    action TEXT NOT NULL,
+
+    CONSTRAINT moderation_rules_kind_check
+    CHECK (kind IN ('word', 'regex')),
+
+    CONSTRAINT moderation_rules_action_check
+    CHECK (action IN ('mask', 'hold', 'reject'))
+);
+
+-- The words the profanity filter used to have hard-coded
+INSERT INTO moderation_rules (id, created_at, updated_at, kind, pattern, action)
+VALUES
+    (gen_random_uuid(), NOW(), NOW(), 'word', 'kerfuffle', 'mask'),
+    (gen_random_uuid(), NOW(), NOW(), 'word', 'sharbert', 'mask'),
+    (gen_random_uuid(), NOW(), NOW(), 'word', 'fornax', 'mask');
+
+-- Chirps held for review are kept as submitted and only become chirps
+-- once a moderator approves them
+CREATE TABLE held_chirps (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    body TEXT NOT NULL,
+    payload JSONB NOT NULL,
+
+    CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE held_chirps;
+DROP TABLE moderation_rules;
+
+ALTER TABLE users
+DROP COLUMN is_admin;
\ No newline at end of file
@@ -0,0 +1,52 @@
+-- +goose Up
+ALTER TABLE users
+ADD is_moderator BOOLEAN NOT NULL
+DEFAULT FALSE;
+
+ALTER TABLE users
+ADD suspended_at TIMESTAMP;
+
+-- chirp_id deliberately has no foreign key: reports outlive removed chirps
+-- as a record of what moderators acted on
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    reporter_id UUID NOT NULL,
+    chirp_id UUID NOT NULL,
+    reason TEXT NOT NULL,
+    details TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open',
+    resolved_at TIMESTAMP,
+    resolved_by UUID,
+
+    CONSTRAINT reports_status_check
+    CHECK (status IN ('open', 'dismissed', 'actioned')),
+
+    CONSTRAINT fk_reporter_id
+    FOREIGN KEY (reporter_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE,
+
+    CONSTRAINT fk_resolved_by
+    FOREIGN KEY (resolved_by)
+    REFERENCES users (id)
+    ON DELETE SET NULL
+);
+
+-- One open report per user and chirp
+CREATE UNIQUE INDEX reports_open_reporter_chirp_idx
+ON reports (reporter_id, chirp_id)
+WHERE status = 'open';
+
+CREATE INDEX reports_open_chirp_id_idx
+ON reports (chirp_id)
+WHERE status = 'open';
+
+-- +goose Down
+DROP TABLE reports;
+
+ALTER TABLE users
+DROP COLUMN suspended_at;
+
+ALTER TABLE users
+DROP COLUMN is_moderator;
\ No newline at end of file
@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD deleted_at TIMESTAMP;
+
+-- Who deleted the chirp: its author, who may restore it from the trash,
+-- or a moderator, who removed it for good
+ALTER TABLE chirps
+ADD deleted_by UUID;
+
+CREATE INDEX chirps_deleted_at_idx
+ON chirps (deleted_at)
+WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_deleted_at_idx;
+
+ALTER TABLE chirps
+DROP COLUMN deleted_by;
+
+ALTER TABLE chirps
+DROP COLUMN deleted_at;
\ No newline at end of file
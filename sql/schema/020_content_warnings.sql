@@ -0,0 +1,23 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD content_warning TEXT NOT NULL
+DEFAULT '';
+
+ALTER TABLE chirps
+ADD sensitive BOOLEAN NOT NULL
+DEFAULT FALSE;
+
+-- Whether listings show chirps behind a content warning expanded
+ALTER TABLE users
+ADD expand_sensitive BOOLEAN NOT NULL
+DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN expand_sensitive;
+
+ALTER TABLE chirps
+DROP COLUMN sensitive;
+
+ALTER TABLE chirps
+DROP COLUMN content_warning;
\ No newline at end of file
@@ -0,0 +1,37 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD visibility TEXT NOT NULL
+DEFAULT 'public';
+
+ALTER TABLE chirps
+ADD CONSTRAINT chirps_visibility_check
+CHECK (visibility IN ('public', 'followers', 'mentioned'));
+
+-- Users a chirp mentions, who can see it whatever its visibility
+CREATE TABLE chirp_mentions (
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+
+    PRIMARY KEY (chirp_id, user_id),
+
+    CONSTRAINT fk_chirp_id
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps (id)
+    ON DELETE CASCADE,
+
+    CONSTRAINT fk_user_id
+    FOREIGN KEY (user_id)
+    REFERENCES users (id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_mentions_user_id_idx ON chirp_mentions (user_id);
+
+-- +goose Down
+DROP TABLE chirp_mentions;
+
+ALTER TABLE chirps
+DROP CONSTRAINT chirps_visibility_check;
+
+ALTER TABLE chirps
+DROP COLUMN visibility;
\ No newline at end of file
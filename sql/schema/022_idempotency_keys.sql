@@ -0,0 +1,20 @@
+-- +goose Up
+-- Responses to POST requests carrying an Idempotency-Key header, replayed
+-- when a client retries. scope is the endpoint plus who made the request;
+-- status_code is NULL while the first request is still being handled.
+CREATE TABLE idempotency_keys (
+    scope TEXT NOT NULL,
+    key TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    request_hash TEXT NOT NULL,
+    status_code INTEGER,
+    content_type TEXT NOT NULL DEFAULT '',
+    response_body BYTEA,
+
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX idempotency_keys_created_at_idx ON idempotency_keys (created_at);
+
+-- +goose Down
+DROP TABLE idempotency_keys;
\ No newline at end of file
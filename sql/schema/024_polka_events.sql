@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE polka_events (
+    id TEXT PRIMARY KEY,
+    received_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'processing',
+    attempts INTEGER NOT NULL DEFAULT 1,
+    error TEXT,
+    processed_at TIMESTAMP,
+
+    CONSTRAINT polka_events_status_check
+    CHECK (status IN ('processing', 'processed', 'ignored', 'failed'))
+);
+
+CREATE INDEX polka_events_received_at_idx ON polka_events (received_at DESC);
+
+-- +goose Down
+DROP TABLE polka_events;
\ No newline at end of file
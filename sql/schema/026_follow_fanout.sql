@@ -0,0 +1,36 @@
+-- +goose Up
+-- Whether the followee's chirps reach this follower on read rather than
+-- being fanned out on write, decided once when the follow is made
+ALTER TABLE follows
+ADD fanout_on_read BOOLEAN NOT NULL
+DEFAULT FALSE;
+
+-- Existing follows of accounts above the default threshold were already
+-- served on read. Migrations can't see FANOUT_FOLLOWER_THRESHOLD, so this
+-- assumes the default of 10000; with a different threshold, existing
+-- follows keep the split made here and only new follows use it
+UPDATE follows
+SET fanout_on_read = TRUE
+WHERE followee_id IN (
+    SELECT followee_id FROM follows
+    GROUP BY followee_id
+    HAVING COUNT(*) > 10000
+);
+
+-- Their chirps are read from the followee directly, entries fanned out
+-- before the account crossed the threshold would show up twice
+DELETE FROM timeline_entries
+USING follows, chirps
+WHERE follows.fanout_on_read
+AND timeline_entries.user_id = follows.follower_id
+AND timeline_entries.chirp_id = chirps.id
+AND chirps.user_id = follows.followee_id;
+
+CREATE INDEX chirps_user_id_created_at_idx
+ON chirps (user_id, created_at DESC);
+
+-- +goose Down
+DROP INDEX chirps_user_id_created_at_idx;
+
+ALTER TABLE follows
+DROP COLUMN fanout_on_read;
\ No newline at end of file
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Claims are leased: one left behind by a replica that died mid-request
+-- can be taken over once its lease runs out, rather than answering 409
+-- until the key expires
+ALTER TABLE idempotency_keys
+ADD locked_until TIMESTAMP NOT NULL
+DEFAULT NOW();
+
+-- +goose Down
+ALTER TABLE idempotency_keys
+DROP COLUMN locked_until;
\ No newline at end of file
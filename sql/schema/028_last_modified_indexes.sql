@@ -0,0 +1,13 @@
+-- +goose Up
+-- Last-Modified of chirp listings is the newest chirp change or poll
+-- vote, both read off these indexes
+CREATE INDEX chirps_updated_at_idx
+ON chirps (updated_at);
+
+CREATE INDEX poll_votes_created_at_idx
+ON poll_votes (created_at);
+
+-- +goose Down
+DROP INDEX poll_votes_created_at_idx;
+
+DROP INDEX chirps_updated_at_idx;
\ No newline at end of file
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Chirpy Red is read off the subscription, a copy on the user could
+-- drift from it
+ALTER TABLE users
+DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users
+ADD is_chirpy_red BOOLEAN
+DEFAULT FALSE;
+
+UPDATE users
+SET is_chirpy_red = TRUE
+FROM subscriptions
+WHERE subscriptions.user_id = users.id
+AND subscriptions.status <> 'expired'
+AND subscriptions.current_period_end > NOW();
\ No newline at end of file